@@ -0,0 +1,148 @@
+package snowberry
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// pendingAssignment is an input of a batch, scored against the clusters which existed before the batch
+type pendingAssignment struct {
+	fruit     *fruit
+	debug     *AssignDebug
+	rejected  bool
+	near      []nearBranch
+	bestMatch *fruit
+	bestScore float32
+}
+
+// AssignBatch assigns every input and returns the results in the same order. Masking, rejection and scoring against
+// the clusters which existed before the batch run in parallel, after which the assignments are committed in order, so
+// the results equal those of calling Assign for each input in turn. The Counter is locked for the whole batch.
+// Every input is recorded as occurring at the time the batch was committed.
+// When ctx is done, AssignBatch stops and returns the results of the inputs assigned so far along with ctx.Err().
+func (c *Counter) AssignBatch(ctx context.Context, inputs []string) ([]AssignResult, error) {
+	pending := make([]pendingAssignment, len(inputs))
+
+	err := parallel(ctx, len(inputs), func(i int) {
+		p := &pending[i]
+		p.debug = &AssignDebug{Input: inputs[i]}
+		p.fruit, p.rejected = c.prepare(inputs[i], p.debug)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]AssignResult, 0, len(inputs))
+	defer func() {
+		if c.debugChannel != nil {
+			for _, p := range pending[:len(results)] {
+				c.debugChannel <- p.debug
+			}
+		}
+	}()
+
+	var evicted []*cluster
+	c.lock.Lock()
+	defer func() {
+		c.lock.Unlock()
+		c.reportEvicted(evicted)
+	}()
+
+	since, at := c.created, time.Now()
+	err = parallel(ctx, len(inputs), func(i int) {
+		p := &pending[i]
+		if !p.rejected {
+			p.near = c.tree.findNearBranches(p.fruit, c.maxKeyEdits)
+			p.bestMatch, p.bestScore = c.bestMatch(p.fruit, p.near, nil, 0, 0)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pending {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		p := &pending[i]
+		if p.rejected {
+			results = append(results, AssignResult{Rejected: true})
+			continue
+		}
+
+		// Clusters created or evicted earlier in the batch may have changed the tree, and eviction may have removed the
+		// best match, either of which means comparing from scratch. Otherwise, only the clusters created earlier in the
+		// batch remain to be compared.
+		near := c.tree.findNearBranches(p.fruit, c.maxKeyEdits)
+		if sameNearBranches(near, p.near) && !c.evicted(p.bestMatch) {
+			if p.bestScore < 1 {
+				p.bestMatch, p.bestScore = c.bestMatch(p.fruit, near, p.bestMatch, p.bestScore, since)
+			}
+		} else {
+			p.bestMatch, p.bestScore = c.bestMatch(p.fruit, near, nil, 0, 0)
+		}
+
+		cl, score, created := c.settle(near[0].branch, p.fruit, p.bestMatch, p.bestScore, p.debug)
+		cl.add(p.fruit, 1, at)
+		c.touch(cl)
+		evicted = append(evicted, c.evict(cl)...)
+
+		results = append(results, cl.assignResult(p.fruit, score, created))
+	}
+
+	return results, nil
+}
+
+// sameNearBranches reports whether both have the same terminating branch and the same siblings, in any order
+func sameNearBranches(a, b []nearBranch) bool {
+	if len(a) != len(b) || a[0] != b[0] {
+		return false
+	}
+
+	siblings := make(map[nearBranch]struct{}, len(a)-1)
+	for _, s := range a[1:] {
+		siblings[s] = struct{}{}
+	}
+	for _, s := range b[1:] {
+		if _, ok := siblings[s]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parallel calls f for every index from 0 to n, spread over one goroutine per CPU, and returns ctx.Err() when ctx is
+// done before every call was made
+func parallel(ctx context.Context, n int, f func(i int)) error {
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(n, runtime.GOMAXPROCS(0)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				f(i)
+			}
+		}()
+	}
+
+	err := ctx.Err()
+	for i := 0; i < n && err == nil; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return err
+}
@@ -0,0 +1,75 @@
+package snowberry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignBatch(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	formats := []string{
+		"Connection reset by peer %d",
+		"Connection refused on port %d",
+		"Disk quota exceeded for user %d",
+		"User %d logged in",
+		"User %d logged out",
+		"%d",
+		"Mango number %d is ripe",
+	}
+
+	var inputs []string
+	for i := 0; i < 2000; i++ {
+		inputs = append(inputs, fmt.Sprintf(formats[r.Intn(len(formats))], r.Intn(100000)))
+	}
+
+	for _, maxKeyEdits := range []int{0, 1} {
+		newCounter := func() *Counter {
+			return NewCounter(2, 0.75).
+				WithFuzzyTraversal(maxKeyEdits).
+				WithRejectAssign([]*regexp.Regexp{regexp.MustCompile(`^\d+$`)})
+		}
+
+		sequential := newCounter()
+		var expected []AssignResult
+		for _, s := range inputs {
+			expected = append(expected, sequential.Assign(s))
+		}
+
+		batched := newCounter()
+		var actual []AssignResult
+		for i := 0; i < len(inputs); i += 500 {
+			results, err := batched.AssignBatch(context.Background(), inputs[i:i+500])
+			assert.NoError(t, err)
+			actual = append(actual, results...)
+		}
+
+		assert.Equal(t, expected, actual, maxKeyEdits)
+		assert.Equal(t, withoutTimes(sequential.Clusters()), withoutTimes(batched.Clusters()), maxKeyEdits)
+	}
+}
+
+func TestAssignBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewCounter(2, 0.75)
+	results, err := c.AssignBatch(ctx, []string{"a", "b", "c"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, results)
+	assert.Empty(t, c.Clusters())
+}
+
+// withoutTimes clears the times of clusters, for comparing clusters assigned at different times
+func withoutTimes(clusters []Cluster) []Cluster {
+	for i := range clusters {
+		clusters[i].FirstSeen, clusters[i].LastSeen = time.Time{}, time.Time{}
+	}
+
+	return clusters
+}
@@ -0,0 +1,76 @@
+package snowberry
+
+import "sort"
+
+// Classify finds the category input would be assigned to, without assigning it. Unlike Assign, an input which matches
+// no category above the threshold does not create one, and counts are left untouched. Classify returns false when the
+// input was rejected or matched no category; the result's Rejected field tells the two apart.
+func (c *Counter) Classify(input string) (AssignResult, bool) {
+	n, rejected := c.prepare(input, &AssignDebug{Input: input})
+	if rejected {
+		return AssignResult{Rejected: true}, false
+	}
+
+	defer c.readLock()()
+
+	_, bestMatch, bestScore := c.search(c.tree, n)
+	if bestMatch == nil || bestScore <= c.scoreThreshold {
+		return AssignResult{}, false
+	}
+
+	return AssignResult{ID: bestMatch.id, Representative: bestMatch.original, Score: bestScore, Masks: n.masks}, true
+}
+
+// Candidate is a category an input was scored against
+type Candidate struct {
+	ID             ClusterID
+	Representative string
+	Score          float32
+}
+
+// Candidates scores input against every category in its candidate set, the same set Assign chooses the best match
+// from, and returns the `n` best scoring categories, best first, regardless of the threshold. It also returns the margin
+// between the scores of the first and second candidate, or the first score if there is only one. A small margin marks an
+// input which sits between two categories. Nothing is assigned, and rejected inputs have no candidates.
+func (c *Counter) Candidates(input string, n int) ([]Candidate, float32) {
+	f, rejected := c.prepare(input, &AssignDebug{Input: input})
+	if rejected || n < 1 {
+		return nil, 0
+	}
+
+	defer c.readLock()()
+
+	type scored struct {
+		fruit *fruit
+		score float32
+	}
+
+	var all []scored
+	for _, b := range c.tree.findNearBranches(f, c.maxKeyEdits) {
+		for _, fr := range b.allDescendantFruit() {
+			all = append(all, scored{fruit: fr, score: f.compare(b.start, fr, c.scorer)})
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+
+		return all[i].fruit.created < all[j].fruit.created
+	})
+
+	candidates := make([]Candidate, 0, min(n, len(all)))
+	for _, s := range all[:min(n, len(all))] {
+		candidates = append(candidates, Candidate{ID: s.fruit.id, Representative: s.fruit.original, Score: s.score})
+	}
+
+	switch len(all) {
+	case 0:
+		return candidates, 0
+	case 1:
+		return candidates, all[0].score
+	}
+
+	return candidates, all[0].score - all[1].score
+}
@@ -0,0 +1,63 @@
+package snowberry
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	for _, c := range []*Counter{NewCounter(2, 0.70), NewShardedCounter(2, 0.70)} {
+		c.WithRejectAssign([]*regexp.Regexp{regexp.MustCompile("\\d{4}")})
+
+		snake := c.Assign("There's a snake in my boot.")
+		c.Assign("To infinity and beyond!")
+		before := c.Clusters()
+
+		result, ok := c.Classify("There's a snail in my boot.")
+		assert.True(t, ok)
+		assert.Equal(t, snake.ID, result.ID)
+		assert.Equal(t, "There's a snake in my boot.", result.Representative)
+		assert.Greater(t, result.Score, float32(0.70))
+		assert.False(t, result.Created)
+
+		result, ok = c.Classify("You've got a friend in me.")
+		assert.False(t, ok)
+		assert.Equal(t, AssignResult{}, result)
+
+		result, ok = c.Classify("2024-09-08T23:30:03.333")
+		assert.False(t, ok)
+		assert.True(t, result.Rejected)
+
+		// Nothing was created or counted
+		assert.Equal(t, before, c.Clusters())
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	c := NewCounter(2, 0.95)
+
+	snake := c.Assign("There's a snake in my boot.")
+	snail := c.Assign("There's a snail in my boot.")
+	boot := c.Assign("There's a boot in my boot.")
+	c.Assign("To infinity and beyond!")
+
+	candidates, margin := c.Candidates("There's a snack in my boot.", 5)
+	assert.Len(t, candidates, 2)
+	assert.Equal(t, []ClusterID{snake.ID, snail.ID}, []ClusterID{candidates[0].ID, candidates[1].ID})
+	assert.InDelta(t, candidates[0].Score-candidates[1].Score, margin, 0.0001)
+
+	candidates, margin = c.Candidates("There's a bxoot in my boot.", 10)
+	assert.Len(t, candidates, 3)
+	assert.Equal(t, boot.ID, candidates[0].ID)
+	assert.GreaterOrEqual(t, candidates[1].Score, candidates[2].Score)
+	assert.InDelta(t, candidates[0].Score-candidates[1].Score, margin, 0.0001)
+
+	// Nothing was assigned
+	assert.Len(t, c.Clusters(), 4)
+
+	candidates, margin = c.Candidates("Zzz", 0)
+	assert.Empty(t, candidates)
+	assert.Equal(t, float32(0), margin)
+}
@@ -0,0 +1,294 @@
+package snowberry
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClusterID is an opaque identifier of a group of similar strings. It is derived from the masked string which created
+// the group, so the same input creates a group with the same ID in every Counter with the same configuration, and the ID
+// is kept for the lifetime of the group.
+type ClusterID string
+
+func newClusterID(masked string) ClusterID {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(masked))
+
+	return ClusterID(strconv.FormatUint(h.Sum64(), 36))
+}
+
+// cluster holds the state of a group of similar strings, represented in the tree by fruit
+type cluster struct {
+	fruit *fruit
+	count int
+	// template holds the tokens of the members, with the tokens which vary between members replaced by a wildcard
+	template []string
+	// variants samples the originals of the members, nil unless the Counter keeps exemplars
+	variants *variants
+	// firstSeen and lastSeen are the earliest and latest times of the members
+	firstSeen, lastSeen time.Time
+	// history counts members over time, nil unless the Counter keeps history
+	history *history
+	// rates tracks the rate of members, nil unless the Counter tracks rates
+	rates *rates
+	// used is the assignment clock of the Counter when the cluster was last assigned to, and aging the eviction age of
+	// the Counter at that time, kept only when the Counter evicts clusters. element and index place the cluster in the
+	// eviction order.
+	used    uint64
+	aging   int
+	element *list.Element
+	index   int
+}
+
+// add counts f, which occurred n times at the provided time, as a member of the cluster
+func (cl *cluster) add(f *fruit, n int, at time.Time) {
+	cl.count += n
+
+	if cl.firstSeen.IsZero() || at.Before(cl.firstSeen) {
+		cl.firstSeen = at
+	}
+	if at.After(cl.lastSeen) {
+		cl.lastSeen = at
+	}
+	if cl.history != nil {
+		cl.history.add(at, n)
+	}
+	if cl.rates != nil {
+		cl.rates.add(at, n)
+	}
+
+	if cl.template == nil {
+		cl.template = templateTokens(f.original)
+	} else {
+		cl.template = mergeTemplate(cl.template, templateTokens(f.original))
+	}
+
+	if cl.variants != nil {
+		cl.variants.add(f.original)
+	}
+}
+
+// remove uncounts n members. Unless at is zero, they are also taken out of the history and rates at the provided time.
+// First and last seen, the template and the exemplars are kept as they are.
+func (cl *cluster) remove(n int, at time.Time) {
+	cl.count -= n
+	if at.IsZero() {
+		return
+	}
+
+	if cl.history != nil {
+		cl.history.remove(at, n)
+	}
+	if cl.rates != nil {
+		cl.rates.remove(at, n)
+	}
+}
+
+// newCluster registers a new cluster represented by f and assigns f its ID. Two clusters with the same masked string,
+// only possible with a threshold of 1 or above, are told apart by a suffix.
+func (c *Counter) newCluster(f *fruit) *cluster {
+	c.clustersLock.Lock()
+	defer c.clustersLock.Unlock()
+
+	id := newClusterID(f.masked)
+	for i := 1; c.clusters[id] != nil; i++ {
+		id = newClusterID(f.masked) + ClusterID("-"+strconv.Itoa(i))
+	}
+
+	return c.registerCluster(f, id)
+}
+
+// registerCluster registers a new cluster with the provided ID, represented by f
+func (c *Counter) registerCluster(f *fruit, id ClusterID) *cluster {
+	c.created++
+	f.id = id
+	f.created = c.created
+	cl := &cluster{fruit: f}
+	if c.exemplars > 0 {
+		cl.variants = newVariants(c.exemplars)
+	}
+	if c.historyBucket > 0 {
+		cl.history = newHistory(c.historyBucket, c.historyRetention)
+	}
+	if c.rateHalfLife > 0 {
+		cl.rates = newRates(c.rateHalfLife, c.baselineHalfLife)
+	}
+	c.clusters[id] = cl
+	if c.evictionOrder != nil {
+		c.evictionOrder.push(cl)
+	}
+
+	return cl
+}
+
+// cluster returns the cluster with the provided ID, safe for use by concurrent shards
+func (c *Counter) cluster(id ClusterID) *cluster {
+	c.clustersLock.Lock()
+	defer c.clustersLock.Unlock()
+
+	return c.clusters[id]
+}
+
+// assignResult describes the assignment of f to the cluster
+func (cl *cluster) assignResult(f *fruit, score float32, created bool) AssignResult {
+	return AssignResult{ID: cl.fruit.id, Representative: cl.fruit.original, Score: score, Created: created, Masks: f.masks}
+}
+
+// clone returns a copy of the cluster's state, which shares the immutable representative fruit
+func (cl *cluster) clone() *cluster {
+	clone := *cl
+	if cl.variants != nil {
+		clone.variants = cl.variants.clone()
+	}
+	if cl.history != nil {
+		clone.history = cl.history.clone()
+	}
+	if cl.rates != nil {
+		clone.rates = cl.rates.clone()
+	}
+
+	return &clone
+}
+
+// absorb adds the members of other to the cluster
+func (cl *cluster) absorb(other *cluster) {
+	cl.count += other.count
+
+	if cl.template == nil {
+		cl.template = other.template
+	} else if other.template != nil {
+		cl.template = mergeTemplate(cl.template, other.template)
+	}
+
+	switch {
+	case cl.variants == nil:
+	case other.variants != nil:
+		cl.variants.merge(other.variants)
+	default:
+		cl.variants.add(other.fruit.original)
+	}
+
+	if !other.firstSeen.IsZero() && (cl.firstSeen.IsZero() || other.firstSeen.Before(cl.firstSeen)) {
+		cl.firstSeen = other.firstSeen
+	}
+	if other.lastSeen.After(cl.lastSeen) {
+		cl.lastSeen = other.lastSeen
+	}
+	// Buckets of different widths and rates of different half-lives cannot be added up
+	if cl.history != nil && other.history != nil && cl.history.width == other.history.width {
+		cl.history.merge(other.history)
+	}
+	if cl.rates != nil && other.rates != nil && cl.rates.decay == other.rates.decay &&
+		cl.rates.baselineDecay == other.rates.baselineDecay {
+		cl.rates.merge(other.rates)
+	}
+}
+
+// Cluster describes a group of similar strings
+type Cluster struct {
+	ID ClusterID
+	// Representative is the original input which created the group
+	Representative string
+	// MaskedRepresentative is the representative after masking, which is what inputs are compared against
+	MaskedRepresentative string
+	// Template is the representative with every part which varies between members replaced by "<*>"
+	Template string
+	Count    int
+	// Exemplars is a sample of the distinct originals of the members, empty unless the Counter keeps exemplars
+	Exemplars []string
+	// DistinctVariants is the number of distinct originals among the members, estimated once there are many, and 0
+	// unless the Counter keeps exemplars
+	DistinctVariants int
+	// FirstSeen and LastSeen are the earliest and latest times members occurred at
+	FirstSeen, LastSeen time.Time
+	// History counts the members over time, oldest first, empty unless the Counter keeps history
+	History []HistoryBucket
+}
+
+func (cl *cluster) describe() Cluster {
+	d := Cluster{
+		ID:                   cl.fruit.id,
+		Representative:       cl.fruit.original,
+		MaskedRepresentative: cl.fruit.masked,
+		Template:             strings.Join(cl.template, " "),
+		Count:                cl.count,
+		FirstSeen:            cl.firstSeen,
+		LastSeen:             cl.lastSeen,
+	}
+
+	if cl.variants != nil {
+		d.Exemplars = cl.variants.originals()
+		d.DistinctVariants = cl.variants.distinct()
+	}
+	if cl.history != nil {
+		d.History = cl.history.describe()
+	}
+
+	return d
+}
+
+// sortedClusters returns all clusters in order of creation
+func (c *Counter) sortedClusters() []*cluster {
+	clusters := make([]*cluster, 0, len(c.clusters))
+	for _, cl := range c.clusters {
+		clusters = append(clusters, cl)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].fruit.created < clusters[j].fruit.created
+	})
+
+	return clusters
+}
+
+// Cluster returns the group with the provided ID, if it exists
+func (c *Counter) Cluster(id ClusterID) (Cluster, bool) {
+	defer c.readLock()()
+
+	cl, ok := c.clusters[id]
+	if !ok {
+		return Cluster{}, false
+	}
+
+	return cl.describe(), true
+}
+
+// Clusters returns every group, in order of creation
+func (c *Counter) Clusters() []Cluster {
+	defer c.readLock()()
+
+	clusters := make([]Cluster, 0, len(c.clusters))
+	for _, cl := range c.sortedClusters() {
+		clusters = append(clusters, cl.describe())
+	}
+
+	return clusters
+}
+
+// Templates returns the map of group templates and counts. Groups with the same template are counted together.
+func (c *Counter) Templates() map[string]int {
+	defer c.readLock()()
+
+	templates := make(map[string]int)
+	for _, cl := range c.clusters {
+		templates[strings.Join(cl.template, " ")] += cl.count
+	}
+
+	return templates
+}
+
+// CountsByID returns the map of group IDs and counts
+func (c *Counter) CountsByID() map[ClusterID]int {
+	defer c.readLock()()
+
+	counts := make(map[ClusterID]int, len(c.clusters))
+	for id, cl := range c.clusters {
+		counts[id] = cl.count
+	}
+
+	return counts
+}
@@ -0,0 +1,59 @@
+package snowberry
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusters(t *testing.T) {
+	c := NewCounter(2, 0.70)
+
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+	snake := c.AssignAt("There's a snake in my boot.", at)
+	c.AssignAt("There's a snail in my boot.", at.Add(time.Minute))
+	infinity := c.AssignAt("To infinity and beyond!", at)
+
+	cl, ok := c.Cluster(snake.ID)
+	assert.True(t, ok)
+	assert.Equal(t, Cluster{
+		ID:                   snake.ID,
+		Representative:       "There's a snake in my boot.",
+		MaskedRepresentative: "There's a snake in my boot.",
+		Template:             "There's a <*> in my boot.",
+		Count:                2,
+		FirstSeen:            at,
+		LastSeen:             at.Add(time.Minute),
+	}, cl)
+
+	_, ok = c.Cluster("unknown")
+	assert.False(t, ok)
+
+	assert.Equal(t, []ClusterID{snake.ID, infinity.ID}, []ClusterID{c.Clusters()[0].ID, c.Clusters()[1].ID})
+	assert.Equal(t, map[ClusterID]int{snake.ID: 2, infinity.ID: 1}, c.CountsByID())
+}
+
+func TestClusterIDsAreStable(t *testing.T) {
+	f := []string{"abc 1", "abc 2", "xyz 1"}
+	ignore := []*regexp.Regexp{regexp.MustCompile(`\d`)}
+
+	// Threshold 1 can never be exceeded, so every input creates a cluster, even when the masked strings are equal
+	build := func() []ClusterID {
+		c := NewCounter(2, 1).WithIgnoreAssign(ignore)
+
+		var ids []ClusterID
+		for _, s := range f {
+			ids = append(ids, c.Assign(s).ID)
+		}
+
+		return ids
+	}
+
+	ids := build()
+	assert.Len(t, ids, 3)
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.NotEqual(t, ids[1], ids[2])
+	assert.Equal(t, ids, build())
+}
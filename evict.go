@@ -0,0 +1,231 @@
+package snowberry
+
+import (
+	"container/heap"
+	"container/list"
+	"sort"
+)
+
+// EvictionPolicy decides which category a Counter with a maximum number of categories removes to make room
+type EvictionPolicy int
+
+const (
+	// EvictLeastRecentlyUsed removes the category which was assigned to least recently
+	EvictLeastRecentlyUsed EvictionPolicy = iota
+	// EvictLeastFrequentlyUsed removes the category with the fewest members, aged so that a category which grew large
+	// long ago eventually gives way to newer ones: every category starts from the count of the last evicted category
+	// as of its last assignment, as in LFU with dynamic aging
+	EvictLeastFrequentlyUsed
+	// EvictLowestCount removes the category with the fewest members
+	EvictLowestCount
+	// EvictOldest removes the category which was created first
+	EvictOldest
+)
+
+// WithMaxClusters returns a Counter which holds no more than `max` categories. Whenever an assignment or Merge creates
+// one too many, the category chosen by `policy` is removed from the index, other than the one just assigned to. Ties
+// go to the oldest category. `onEvict`, if not nil, is called with every removed category once the Counter is unlocked,
+// so it may call the Counter. A `max` below 1 means no maximum. A sharded Counter with a maximum assigns exclusively.
+func (c *Counter) WithMaxClusters(max int, policy EvictionPolicy, onEvict func(Cluster)) *Counter {
+	c.maxClusters = max
+	c.evictionPolicy = policy
+	c.onEvict = onEvict
+	c.resetEvictionOrder()
+
+	return c
+}
+
+// evictionOrder keeps clusters in the order the eviction policy removes them
+type evictionOrder interface {
+	push(cl *cluster)
+	remove(cl *cluster)
+	// used and counted restore the order after cl was assigned to, or its count changed otherwise
+	used(cl *cluster)
+	counted(cl *cluster)
+	// next returns the cluster to evict first, other than keep
+	next(keep *cluster) *cluster
+}
+
+// resetEvictionOrder orders the existing clusters for the eviction policy, or drops the order without a maximum
+func (c *Counter) resetEvictionOrder() {
+	c.evictionOrder = nil
+	if c.maxClusters <= 0 {
+		return
+	}
+
+	clusters := c.sortedClusters()
+	switch c.evictionPolicy {
+	case EvictLeastRecentlyUsed:
+		c.evictionOrder = &listOrder{list: list.New(), recency: true}
+		sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].used < clusters[j].used })
+	case EvictLeastFrequentlyUsed:
+		c.evictionOrder = &heapOrder{less: func(a, b *cluster) bool {
+			if a.aging+a.count != b.aging+b.count {
+				return a.aging+a.count < b.aging+b.count
+			}
+
+			return a.fruit.created < b.fruit.created
+		}}
+	case EvictLowestCount:
+		c.evictionOrder = &heapOrder{less: func(a, b *cluster) bool {
+			if a.count != b.count {
+				return a.count < b.count
+			}
+
+			return a.fruit.created < b.fruit.created
+		}}
+	default:
+		c.evictionOrder = &listOrder{list: list.New()}
+	}
+
+	for _, cl := range clusters {
+		c.evictionOrder.push(cl)
+	}
+}
+
+// touch records an assignment to cl, for eviction
+func (c *Counter) touch(cl *cluster) {
+	if c.evictionOrder == nil {
+		return
+	}
+
+	c.clock++
+	cl.used = c.clock
+	cl.aging = c.evictionAge
+	c.evictionOrder.used(cl)
+}
+
+// evict removes clusters, other than keep, until there are no more than the maximum, and returns them
+func (c *Counter) evict(keep *cluster) []*cluster {
+	var evicted []*cluster
+	for c.evictionOrder != nil && len(c.clusters) > c.maxClusters {
+		victim := c.evictionOrder.next(keep)
+		if c.evictionPolicy == EvictLeastFrequentlyUsed {
+			c.evictionAge = max(c.evictionAge, victim.aging+victim.count)
+		}
+
+		c.removeCluster(victim)
+		evicted = append(evicted, victim)
+	}
+
+	return evicted
+}
+
+// evicted reports whether f no longer represents a cluster
+func (c *Counter) evicted(f *fruit) bool {
+	if f == nil {
+		return false
+	}
+
+	cl, ok := c.clusters[f.id]
+
+	return !ok || cl.fruit != f
+}
+
+// reportEvicted passes the evicted clusters to the eviction callback. The clusters are no longer part of the Counter,
+// so the Counter need not be locked.
+func (c *Counter) reportEvicted(evicted []*cluster) {
+	if c.onEvict == nil {
+		return
+	}
+
+	for _, cl := range evicted {
+		c.onEvict(cl.describe())
+	}
+}
+
+// listOrder evicts clusters in the order they were created, or last assigned to when ordering by recency
+type listOrder struct {
+	list    *list.List
+	recency bool
+}
+
+func (o *listOrder) push(cl *cluster) {
+	cl.element = o.list.PushBack(cl)
+}
+
+func (o *listOrder) remove(cl *cluster) {
+	o.list.Remove(cl.element)
+	cl.element = nil
+}
+
+func (o *listOrder) used(cl *cluster) {
+	if o.recency {
+		o.list.MoveToBack(cl.element)
+	}
+}
+
+func (o *listOrder) counted(*cluster) {}
+
+func (o *listOrder) next(keep *cluster) *cluster {
+	e := o.list.Front()
+	if e.Value == keep {
+		e = e.Next()
+	}
+
+	return e.Value.(*cluster)
+}
+
+// heapOrder evicts the least cluster first
+type heapOrder struct {
+	clusters []*cluster
+	less     func(a, b *cluster) bool
+}
+
+func (o *heapOrder) push(cl *cluster) {
+	heap.Push(o, cl)
+}
+
+func (o *heapOrder) remove(cl *cluster) {
+	heap.Remove(o, cl.index)
+}
+
+func (o *heapOrder) used(cl *cluster) {
+	heap.Fix(o, cl.index)
+}
+
+func (o *heapOrder) counted(cl *cluster) {
+	heap.Fix(o, cl.index)
+}
+
+func (o *heapOrder) next(keep *cluster) *cluster {
+	if o.clusters[0] != keep {
+		return o.clusters[0]
+	}
+
+	// The runner-up is one of the children of the least cluster
+	if len(o.clusters) > 2 && o.less(o.clusters[2], o.clusters[1]) {
+		return o.clusters[2]
+	}
+
+	return o.clusters[1]
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface
+func (o *heapOrder) Len() int {
+	return len(o.clusters)
+}
+
+func (o *heapOrder) Less(i, j int) bool {
+	return o.less(o.clusters[i], o.clusters[j])
+}
+
+func (o *heapOrder) Swap(i, j int) {
+	o.clusters[i], o.clusters[j] = o.clusters[j], o.clusters[i]
+	o.clusters[i].index = i
+	o.clusters[j].index = j
+}
+
+func (o *heapOrder) Push(x any) {
+	cl := x.(*cluster)
+	cl.index = len(o.clusters)
+	o.clusters = append(o.clusters, cl)
+}
+
+func (o *heapOrder) Pop() any {
+	cl := o.clusters[len(o.clusters)-1]
+	o.clusters[len(o.clusters)-1] = nil
+	o.clusters = o.clusters[:len(o.clusters)-1]
+
+	return cl
+}
@@ -0,0 +1,135 @@
+package snowberry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEviction(t *testing.T) {
+	words := map[byte]string{
+		'a': "alpha", 'b': "bravo", 'c': "charlie", 'd': "delta", 'e': "echo", 'f': "foxtrot", 'g': "golf",
+	}
+
+	for _, tc := range []struct {
+		inputs   string
+		max      int
+		expected map[EvictionPolicy][]string
+	}{
+		{
+			inputs: "abac",
+			max:    2,
+			expected: map[EvictionPolicy][]string{
+				EvictLeastRecentlyUsed:   {"bravo"},
+				EvictLeastFrequentlyUsed: {"bravo"},
+				EvictLowestCount:         {"bravo"},
+				EvictOldest:              {"alpha"},
+			},
+		},
+		{
+			// alpha has the most members, but each newcomer starts from the count of the last evicted category, so
+			// with aging alpha eventually gives way
+			inputs: "aaabcdefg",
+			max:    2,
+			expected: map[EvictionPolicy][]string{
+				EvictLeastRecentlyUsed:   {"alpha", "bravo", "charlie", "delta", "echo"},
+				EvictLeastFrequentlyUsed: {"bravo", "charlie", "delta", "echo", "alpha"},
+				EvictLowestCount:         {"bravo", "charlie", "delta", "echo", "foxtrot"},
+				EvictOldest:              {"alpha", "bravo", "charlie", "delta", "echo"},
+			},
+		},
+	} {
+		for policy, expected := range tc.expected {
+			var evicted []string
+			c := NewCounter(2, 0.90).WithMaxClusters(tc.max, policy, func(cl Cluster) {
+				evicted = append(evicted, cl.Representative)
+			})
+
+			for i := range tc.inputs {
+				c.Assign(words[tc.inputs[i]])
+			}
+
+			assert.Equal(t, expected, evicted, "%s %d", tc.inputs, policy)
+			assert.Len(t, c.Clusters(), tc.max, "%s %d", tc.inputs, policy)
+			for _, e := range evicted {
+				assert.NotContains(t, c.Counts(), e, "%s %d", tc.inputs, policy)
+				assert.NotContains(t, c.tree.branches, e[:2], "%s %d", tc.inputs, policy)
+			}
+		}
+	}
+}
+
+func TestEvictionNeverEvictsAssigned(t *testing.T) {
+	c := NewCounter(2, 0.90).WithMaxClusters(1, EvictLowestCount, nil)
+	c.Assign("alpha")
+	c.Assign("alpha")
+
+	// The new category has the lowest count, but stays
+	assert.True(t, c.Assign("bravo").Created)
+	assert.Equal(t, map[string]int{"bravo": 1}, c.Counts())
+}
+
+func TestEvictionAfterUnassign(t *testing.T) {
+	c := NewCounter(2, 0.90).WithMaxClusters(2, EvictLowestCount, nil)
+	c.AssignN("alpha", 3)
+	c.AssignN("bravo", 2)
+	c.UnassignN("alpha", 2)
+
+	c.Assign("charlie")
+	assert.Equal(t, map[string]int{"bravo": 2, "charlie": 1}, c.Counts())
+}
+
+func TestEvictionBatchAndSharded(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	formats := []string{
+		"Connection reset by peer %d",
+		"Disk quota exceeded for user %d",
+		"User %d logged in",
+		"Mango number %d is ripe",
+	}
+
+	var inputs []string
+	for i := 0; i < 1000; i++ {
+		inputs = append(inputs, fmt.Sprintf(formats[r.Intn(len(formats))], r.Intn(100000)))
+	}
+
+	for _, policy := range []EvictionPolicy{EvictLeastRecentlyUsed, EvictLeastFrequentlyUsed, EvictLowestCount, EvictOldest} {
+		var expectedEvicted []ClusterID
+		sequential := NewCounter(3, 0.90).WithMaxClusters(20, policy, func(cl Cluster) {
+			expectedEvicted = append(expectedEvicted, cl.ID)
+		})
+		var expected []AssignResult
+		for _, s := range inputs {
+			expected = append(expected, sequential.Assign(s))
+		}
+		assert.NotEmpty(t, expectedEvicted, policy)
+
+		var shardedEvicted []ClusterID
+		sharded := NewShardedCounter(3, 0.90).WithMaxClusters(20, policy, func(cl Cluster) {
+			shardedEvicted = append(shardedEvicted, cl.ID)
+		})
+		var actual []AssignResult
+		for _, s := range inputs {
+			actual = append(actual, sharded.Assign(s))
+		}
+		assert.Equal(t, expected, actual, policy)
+		assert.Equal(t, expectedEvicted, shardedEvicted, policy)
+
+		var batchedEvicted []ClusterID
+		batched := NewCounter(3, 0.90).WithMaxClusters(20, policy, func(cl Cluster) {
+			batchedEvicted = append(batchedEvicted, cl.ID)
+		})
+		actual = nil
+		for i := 0; i < len(inputs); i += 250 {
+			results, err := batched.AssignBatch(context.Background(), inputs[i:i+250])
+			assert.NoError(t, err)
+			actual = append(actual, results...)
+		}
+		assert.Equal(t, expected, actual, policy)
+		assert.Equal(t, expectedEvicted, batchedEvicted, policy)
+		assert.Equal(t, withoutTimes(sequential.Clusters()), withoutTimes(batched.Clusters()), policy)
+	}
+}
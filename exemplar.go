@@ -0,0 +1,123 @@
+package snowberry
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// distinctSketchSize is the number of hashes kept to estimate the number of distinct variants, for a standard error of
+// about 1/sqrt(distinctSketchSize-2)
+const distinctSketchSize = 64
+
+// variants samples the distinct originals of a cluster's members. Every original is hashed, and the originals with the
+// smallest hashes make up the sample, which is uniform over the distinct originals, unaffected by how often each occurs
+// and the same regardless of the order members arrive in. The smallest hashes also estimate the number of distinct
+// originals (k minimum values). Both stay bounded in size, however many members the cluster has.
+type variants struct {
+	size int
+	// exemplars are the originals with the smallest hashes, ordered by hash
+	exemplars []exemplar
+	// hashes are the smallest hashes of all originals, in ascending order
+	hashes []uint64
+}
+
+type exemplar struct {
+	hash     uint64
+	original string
+}
+
+func newVariants(size int) *variants {
+	return &variants{size: size}
+}
+
+// variantHash hashes an original, mixed so that the hashes are spread evenly enough to estimate from
+func variantHash(original string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(original))
+
+	// Finalizer of MurmurHash3
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
+
+// add samples an original
+func (v *variants) add(original string) {
+	hash := variantHash(original)
+	v.addHash(hash)
+	v.addExemplar(hash, original)
+}
+
+// merge adds the samples of other, which results in the same samples as adding every original of other to v
+func (v *variants) merge(other *variants) {
+	for _, hash := range other.hashes {
+		v.addHash(hash)
+	}
+	for _, e := range other.exemplars {
+		v.addExemplar(e.hash, e.original)
+	}
+}
+
+func (v *variants) addHash(hash uint64) {
+	i := sort.Search(len(v.hashes), func(i int) bool { return v.hashes[i] >= hash })
+	if i >= distinctSketchSize || (i < len(v.hashes) && v.hashes[i] == hash) {
+		return
+	}
+
+	v.hashes = append(v.hashes, 0)
+	copy(v.hashes[i+1:], v.hashes[i:])
+	v.hashes[i] = hash
+	if len(v.hashes) > distinctSketchSize {
+		v.hashes = v.hashes[:distinctSketchSize]
+	}
+}
+
+func (v *variants) addExemplar(hash uint64, original string) {
+	i := sort.Search(len(v.exemplars), func(i int) bool { return v.exemplars[i].hash >= hash })
+	if i >= v.size || (i < len(v.exemplars) && v.exemplars[i].hash == hash) {
+		return
+	}
+
+	v.exemplars = append(v.exemplars, exemplar{})
+	copy(v.exemplars[i+1:], v.exemplars[i:])
+	v.exemplars[i] = exemplar{hash: hash, original: original}
+	if len(v.exemplars) > v.size {
+		v.exemplars = v.exemplars[:v.size]
+	}
+}
+
+func (v *variants) clone() *variants {
+	clone := &variants{size: v.size}
+	clone.exemplars = append(clone.exemplars, v.exemplars...)
+	clone.hashes = append(clone.hashes, v.hashes...)
+
+	return clone
+}
+
+// originals returns the sampled originals
+func (v *variants) originals() []string {
+	originals := make([]string, 0, len(v.exemplars))
+	for _, e := range v.exemplars {
+		originals = append(originals, e.original)
+	}
+
+	return originals
+}
+
+// distinct returns the number of distinct originals, exact until the sketch fills up and estimated after
+func (v *variants) distinct() int {
+	if len(v.hashes) < distinctSketchSize {
+		return len(v.hashes)
+	}
+
+	// The k-th smallest of n uniformly spread hashes is expected at about k/n of the range
+	kth := float64(v.hashes[len(v.hashes)-1]) / math.MaxUint64
+
+	return int(math.Round(float64(distinctSketchSize-1) / kth))
+}
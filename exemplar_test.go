@@ -0,0 +1,76 @@
+package snowberry
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariants(t *testing.T) {
+	var originals []string
+	for i := 0; i < 10000; i++ {
+		originals = append(originals, fmt.Sprintf("User %d logged in", i))
+	}
+
+	v := newVariants(5)
+	for _, o := range originals[:10] {
+		v.add(o)
+		v.add(o)
+	}
+	assert.Len(t, v.originals(), 5)
+	assert.Equal(t, 10, v.distinct())
+
+	for _, o := range originals {
+		v.add(o)
+	}
+	assert.Len(t, v.originals(), 5)
+	assert.InEpsilon(t, 10000, v.distinct(), 0.4)
+
+	// The sample does not depend on the order of the originals
+	shuffled := newVariants(5)
+	for _, i := range rand.New(rand.NewSource(4)).Perm(len(originals)) {
+		shuffled.add(originals[i])
+	}
+	assert.Equal(t, v, shuffled)
+
+	// Merging samples equals sampling everything
+	a, b := newVariants(5), newVariants(5)
+	for i, o := range originals {
+		if i%3 == 0 {
+			a.add(o)
+		} else {
+			b.add(o)
+		}
+	}
+	a.merge(b)
+	assert.Equal(t, v, a)
+}
+
+func TestCounterWithExemplars(t *testing.T) {
+	c := NewCounter(5, 0.60).WithExemplars(3)
+
+	for i := 0; i < 20; i++ {
+		c.Assign(fmt.Sprintf("User %d failed login", i%7))
+	}
+	c.Assign("Disk full on /var")
+
+	clusters := c.Clusters()
+	assert.Len(t, clusters, 2)
+
+	assert.Equal(t, 20, clusters[0].Count)
+	assert.Equal(t, 7, clusters[0].DistinctVariants)
+	assert.Len(t, clusters[0].Exemplars, 3)
+	for _, e := range clusters[0].Exemplars {
+		assert.Regexp(t, `^User \d failed login$`, e)
+	}
+
+	assert.Equal(t, []string{"Disk full on /var"}, clusters[1].Exemplars)
+	assert.Equal(t, 1, clusters[1].DistinctVariants)
+
+	c = NewCounter(5, 0.60)
+	c.Assign("Disk full on /var")
+	assert.Empty(t, c.Clusters()[0].Exemplars)
+	assert.Zero(t, c.Clusters()[0].DistinctVariants)
+}
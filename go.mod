@@ -4,8 +4,8 @@ go 1.21
 
 require (
 	github.com/fhalim/csvreader v0.0.0-20190111213759-6467d36621e2
-	github.com/ka-weihe/fast-levenshtein v0.0.0-20201227151214-4c99ee36a1ba
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/text v0.22.0
 )
 
 require (
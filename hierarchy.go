@@ -0,0 +1,126 @@
+package snowberry
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HierarchicalCounter groups strings at several thresholds at once, and relates the categories of neighbouring levels
+// to each other, from broad families at the lowest threshold to specific variants at the highest
+type HierarchicalCounter struct {
+	lock   sync.Mutex
+	levels []*Counter
+	// parents maps the ID of every category of a level to the ID of its parent in the level above, nil for the top level
+	parents []map[ClusterID]ClusterID
+}
+
+// NewHierarchicalCounter returns a HierarchicalCounter with one level per threshold, coarsest first. `newCounter` builds
+// the Counter of each level, so levels can be configured like any Counter, and should configure them alike.
+func NewHierarchicalCounter(scoreThresholds []float32, newCounter func(scoreThreshold float32) *Counter) *HierarchicalCounter {
+	thresholds := append([]float32(nil), scoreThresholds...)
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+
+	h := &HierarchicalCounter{}
+	for i, t := range thresholds {
+		c := newCounter(t)
+		h.levels = append(h.levels, c)
+		if i == 0 {
+			h.parents = append(h.parents, nil)
+			continue
+		}
+
+		parents := make(map[ClusterID]ClusterID)
+		h.parents = append(h.parents, parents)
+
+		// Evicted categories no longer need a parent
+		if onEvict := c.onEvict; c.maxClusters > 0 {
+			c.onEvict = func(cl Cluster) {
+				delete(parents, cl.ID)
+				if onEvict != nil {
+					onEvict(cl)
+				}
+			}
+		}
+	}
+
+	return h
+}
+
+// Assign assigns input at every level, see AssignAt
+func (h *HierarchicalCounter) Assign(input string) []AssignResult {
+	return h.AssignAt(input, time.Now())
+}
+
+// AssignAt assigns input, which occurred at the provided time, at every level, and returns the results coarsest first.
+// A category created by input becomes a child of the category input was assigned to in the level above, and stays its
+// child even if later members of the child are assigned elsewhere in the level above.
+func (h *HierarchicalCounter) AssignAt(input string, at time.Time) []AssignResult {
+	return h.AssignNAt(input, 1, at)
+}
+
+// AssignN assigns input, which occurred n times, at every level, see Counter.AssignN
+func (h *HierarchicalCounter) AssignN(input string, n int) []AssignResult {
+	return h.AssignNAt(input, n, time.Now())
+}
+
+// AssignNAt assigns input, which occurred n times at the provided time, at every level, see AssignAt and
+// Counter.AssignNAt
+func (h *HierarchicalCounter) AssignNAt(input string, n int, at time.Time) []AssignResult {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	results := make([]AssignResult, len(h.levels))
+	for i, c := range h.levels {
+		results[i] = c.AssignNAt(input, n, at)
+		if i > 0 && results[i].Created && results[i-1].ID != "" {
+			h.parents[i][results[i].ID] = results[i-1].ID
+		}
+	}
+
+	return results
+}
+
+// Level returns the Counter of a level, 0 being the coarsest, for queries. Assignments should go through the
+// HierarchicalCounter, so that the levels stay related.
+func (h *HierarchicalCounter) Level(i int) *Counter {
+	return h.levels[i]
+}
+
+// ClusterNode is a category of a HierarchicalCounter, along with the categories of the level below it
+type ClusterNode struct {
+	Cluster
+	// Level is the level of the category, 0 being the coarsest
+	Level    int
+	Children []ClusterNode
+}
+
+// Tree returns the categories of the top level, each with its descendants, in order of creation. Categories whose parent
+// was removed, for example by eviction, are left out.
+func (h *HierarchicalCounter) Tree() []ClusterNode {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.levels) == 0 {
+		return nil
+	}
+
+	// Build from the bottom up, so that every level finds its children complete
+	var children map[ClusterID][]ClusterNode
+	for level := len(h.levels) - 1; level > 0; level-- {
+		parents := make(map[ClusterID][]ClusterNode)
+		for _, cl := range h.levels[level].Clusters() {
+			if parent, ok := h.parents[level][cl.ID]; ok {
+				parents[parent] = append(parents[parent], ClusterNode{Cluster: cl, Level: level, Children: children[cl.ID]})
+			}
+		}
+		children = parents
+	}
+
+	var nodes []ClusterNode
+	for _, cl := range h.levels[0].Clusters() {
+		nodes = append(nodes, ClusterNode{Cluster: cl, Level: 0, Children: children[cl.ID]})
+	}
+
+	return nodes
+}
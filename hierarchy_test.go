@@ -0,0 +1,72 @@
+package snowberry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHierarchicalCounter(t *testing.T) {
+	h := NewHierarchicalCounter([]float32{0.95, 0.60}, func(scoreThreshold float32) *Counter {
+		return NewCounter(2, scoreThreshold)
+	})
+
+	for _, s := range []string{
+		"There's a snake in my boot.",
+		"There's a snail in my boot.",
+		"There's a boot in my boot.",
+		"There's a snail in my boot.",
+		"To infinity and beyond!",
+	} {
+		results := h.Assign(s)
+		assert.Len(t, results, 2)
+	}
+
+	type node struct {
+		Representative string
+		Count          int
+		Children       []node
+	}
+	var describe func(nodes []ClusterNode, level int) []node
+	describe = func(nodes []ClusterNode, level int) []node {
+		var d []node
+		for _, n := range nodes {
+			assert.Equal(t, level, n.Level)
+			d = append(d, node{n.Representative, n.Count, describe(n.Children, level+1)})
+		}
+
+		return d
+	}
+
+	assert.Equal(t, []node{
+		{"There's a snake in my boot.", 4, []node{
+			{"There's a snake in my boot.", 1, nil},
+			{"There's a snail in my boot.", 2, nil},
+			{"There's a boot in my boot.", 1, nil},
+		}},
+		{"To infinity and beyond!", 1, []node{
+			{"To infinity and beyond!", 1, nil},
+		}},
+	}, describe(h.Tree(), 0))
+
+	assert.Equal(t, float32(0.60), h.Level(0).scoreThreshold)
+	assert.Equal(t, float32(0.95), h.Level(1).scoreThreshold)
+}
+
+func TestHierarchicalCounterEviction(t *testing.T) {
+	var evicted []string
+	h := NewHierarchicalCounter([]float32{0.60, 0.95}, func(scoreThreshold float32) *Counter {
+		return NewCounter(2, scoreThreshold).WithMaxClusters(2, EvictOldest, func(cl Cluster) {
+			evicted = append(evicted, cl.Representative)
+		})
+	})
+
+	h.Assign("There's a snake in my boot.")
+	h.Assign("There's a snail in my boot.")
+	h.Assign("There's a boot in my boot.")
+
+	// The callback still sees evictions, and evicted categories leave the tree
+	assert.Equal(t, []string{"There's a snake in my boot."}, evicted)
+	assert.Len(t, h.parents[1], 2)
+	assert.Len(t, h.Tree()[0].Children, 2)
+}
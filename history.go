@@ -0,0 +1,108 @@
+package snowberry
+
+import (
+	"sort"
+	"time"
+)
+
+// HistoryBucket counts the members of a category which occurred within Width of Start
+type HistoryBucket struct {
+	Start time.Time
+	Width time.Duration
+	Count int
+}
+
+// history counts events in fixed width buckets, keeping only the most recent buckets
+type history struct {
+	width     time.Duration
+	retention int
+	// buckets are ordered by index, which is the number of widths since the unix epoch
+	buckets []historyBucket
+}
+
+type historyBucket struct {
+	Index int64 `json:"index"`
+	Count int   `json:"count"`
+}
+
+func newHistory(width time.Duration, retention int) *history {
+	return &history{width: width, retention: retention}
+}
+
+func (h *history) index(at time.Time) int64 {
+	nanos := at.UnixNano()
+	index := nanos / int64(h.width)
+	if nanos%int64(h.width) < 0 {
+		index--
+	}
+
+	return index
+}
+
+// add counts n events at the provided time. Events older than the retained buckets are dropped.
+func (h *history) add(at time.Time, n int) {
+	h.addIndex(h.index(at), n)
+}
+
+// remove uncounts up to n events at the provided time, dropping the bucket when it is left empty. Events of buckets
+// which are no longer retained are ignored.
+func (h *history) remove(at time.Time, n int) {
+	index := h.index(at)
+	i := sort.Search(len(h.buckets), func(i int) bool { return h.buckets[i].Index >= index })
+	if i == len(h.buckets) || h.buckets[i].Index != index {
+		return
+	}
+
+	h.buckets[i].Count -= n
+	if h.buckets[i].Count <= 0 {
+		h.buckets = append(h.buckets[:i], h.buckets[i+1:]...)
+	}
+}
+
+func (h *history) addIndex(index int64, n int) {
+	i := sort.Search(len(h.buckets), func(i int) bool { return h.buckets[i].Index >= index })
+	if i < len(h.buckets) && h.buckets[i].Index == index {
+		h.buckets[i].Count += n
+		return
+	}
+
+	h.buckets = append(h.buckets, historyBucket{})
+	copy(h.buckets[i+1:], h.buckets[i:])
+	h.buckets[i] = historyBucket{Index: index, Count: n}
+
+	if h.retention < 1 {
+		return
+	}
+
+	// Drop every bucket which has fallen out of the retention window of the newest bucket
+	oldest := h.buckets[len(h.buckets)-1].Index - int64(h.retention)
+	drop := sort.Search(len(h.buckets), func(i int) bool { return h.buckets[i].Index > oldest })
+	h.buckets = h.buckets[drop:]
+}
+
+// merge adds the buckets of other, which must have the same width
+func (h *history) merge(other *history) {
+	for _, b := range other.buckets {
+		h.addIndex(b.Index, b.Count)
+	}
+}
+
+func (h *history) clone() *history {
+	clone := *h
+	clone.buckets = append([]historyBucket(nil), h.buckets...)
+
+	return &clone
+}
+
+func (h *history) describe() []HistoryBucket {
+	buckets := make([]HistoryBucket, 0, len(h.buckets))
+	for _, b := range h.buckets {
+		buckets = append(buckets, HistoryBucket{
+			Start: time.Unix(0, b.Index*int64(h.width)),
+			Width: h.width,
+			Count: b.Count,
+		})
+	}
+
+	return buckets
+}
@@ -0,0 +1,82 @@
+package snowberry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+
+	c := NewCounter(5, 0.60).WithHistory(time.Minute, 3)
+	c.AssignAt("User alice failed login", at)
+	c.AssignAt("User bob failed login", at.Add(30*time.Second))
+	c.AssignAt("User carol failed login", at.Add(2*time.Minute))
+	// Out of order, but within retention
+	c.AssignAt("User dave failed login", at.Add(time.Minute+time.Second))
+	// Out of retention
+	c.AssignAt("User erin failed login", at.Add(-time.Hour))
+
+	cl := c.Clusters()[0]
+	assert.Equal(t, 5, cl.Count)
+	assert.True(t, at.Add(-time.Hour).Equal(cl.FirstSeen))
+	assert.True(t, at.Add(2*time.Minute).Equal(cl.LastSeen))
+
+	var starts []time.Time
+	var counts []int
+	for _, b := range cl.History {
+		assert.Equal(t, time.Minute, b.Width)
+		starts = append(starts, b.Start.UTC())
+		counts = append(counts, b.Count)
+	}
+	assert.Equal(t, []time.Time{at, at.Add(time.Minute), at.Add(2 * time.Minute)}, starts)
+	assert.Equal(t, []int{2, 1, 1}, counts)
+
+	// A new bucket pushes the oldest ones out
+	c.AssignAt("User frank failed login", at.Add(3*time.Minute))
+	assert.Len(t, c.Clusters()[0].History, 3)
+	assert.True(t, at.Add(time.Minute).Equal(c.Clusters()[0].History[0].Start))
+}
+
+func TestHistoryMerge(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+
+	a := NewCounter(5, 0.60).WithHistory(time.Hour, 0)
+	a.AssignAt("User alice failed login", at)
+
+	b := NewCounter(5, 0.60).WithHistory(time.Hour, 0)
+	b.AssignAt("User bob failed login", at.Add(-48*time.Hour))
+	b.AssignAt("User carol failed login", at.Add(time.Minute))
+
+	a.Merge(b)
+
+	cl := a.Clusters()[0]
+	assert.Equal(t, 3, cl.Count)
+	assert.True(t, at.Add(-48*time.Hour).Equal(cl.FirstSeen))
+	assert.True(t, at.Add(time.Minute).Equal(cl.LastSeen))
+	assert.Len(t, cl.History, 2)
+	assert.Equal(t, 1, cl.History[0].Count)
+	assert.Equal(t, 2, cl.History[1].Count)
+}
+
+func TestHistoryMergeMismatch(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+
+	a := NewCounter(5, 0.60).WithHistory(time.Hour, 0).WithRates(time.Minute, time.Hour)
+	a.AssignAt("User alice failed login", at)
+
+	b := NewCounter(5, 0.60).WithHistory(time.Minute, 0).WithRates(time.Hour, time.Hour)
+	b.AssignAt("User bob failed login", at.Add(90*time.Minute))
+
+	rate := a.HotClusters(at, 1)[0].Rate
+	a.Merge(b)
+
+	// Counts merge, while history and rates of another width and half-life are left out
+	cl := a.Clusters()[0]
+	assert.Equal(t, 2, cl.Count)
+	assert.Len(t, cl.History, 1)
+	assert.Equal(t, 1, cl.History[0].Count)
+	assert.Equal(t, rate, a.HotClusters(at, 1)[0].Rate)
+}
@@ -0,0 +1,54 @@
+package snowberry
+
+import "regexp"
+
+// Mask replaces the parts of inputs matching Pattern with Replacement before they are compared, so that variable parts,
+// such as numbers or IDs, do not keep otherwise similar inputs apart. A replacement token such as "<NUM>" keeps masked
+// strings readable and keeps "id=123" apart from "id=".
+type Mask struct {
+	// Name identifies the mask in AssignDebug and AssignResult, defaulting to the pattern
+	Name    string
+	Pattern *regexp.Regexp
+	// Replacement replaces every match literally, and may be empty to delete matches
+	Replacement string
+}
+
+func (m Mask) name() string {
+	if m.Name == "" {
+		return m.Pattern.String()
+	}
+
+	return m.Name
+}
+
+// WithMasks returns a Counter which applies the provided masks to assignments, in order, after any earlier masks and
+// before the regex of WithIgnoreAssign
+func (c *Counter) WithMasks(masks ...Mask) *Counter {
+	c.masks = append(c.masks, masks...)
+
+	return c
+}
+
+// maskPatterns returns the pattern of every mask
+func maskPatterns(masks []Mask) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, m := range masks {
+		patterns = append(patterns, m.Pattern)
+	}
+
+	return patterns
+}
+
+// withMasks applies the masks to the masked string, and records the names of the masks which matched
+func (f *fruit) withMasks(masks []Mask) *fruit {
+	for _, m := range masks {
+		if !m.Pattern.MatchString(f.masked) {
+			continue
+		}
+
+		f.masked = m.Pattern.ReplaceAllLiteralString(f.masked, m.Replacement)
+		f.masks = append(f.masks, m.name())
+	}
+
+	return f
+}
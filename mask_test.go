@@ -0,0 +1,60 @@
+package snowberry
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasks(t *testing.T) {
+	debug := make(chan *AssignDebug, 10)
+	c := NewCounter(2, 0.95).
+		WithMasks(
+			Mask{Name: "uuid", Pattern: regexp.MustCompile(`[0-9a-f]{8}(-[0-9a-f]{4}){3}-[0-9a-f]{12}`), Replacement: "<UUID>"},
+			Mask{Name: "number", Pattern: regexp.MustCompile(`\d+`), Replacement: "<NUM>"},
+		).
+		WithIgnoreAssign([]*regexp.Regexp{regexp.MustCompile(`[.!]$`)}).
+		WithDebugChannel(debug)
+
+	first := c.Assign("Request 123e4567-e89b-12d3-a456-426614174000 took 35 ms.")
+	assert.True(t, first.Created)
+	assert.Equal(t, []string{"uuid", "number", "[.!]$"}, first.Masks)
+	d := <-debug
+	assert.Equal(t, "Request <UUID> took <NUM> ms", d.MaskedInput)
+	assert.Equal(t, first.Masks, d.Masks)
+
+	second := c.Assign("Request 00000000-0000-0000-0000-000000000000 took 1200 ms")
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, float32(1), second.Score)
+	assert.Equal(t, []string{"uuid", "number"}, second.Masks)
+	<-debug
+
+	// A replacement token keeps structure which deletion would lose
+	assert.True(t, c.Assign("id=").Created)
+	assert.Nil(t, (<-debug).Masks)
+	assert.True(t, c.Assign("id=123").Created)
+	assert.Equal(t, "id=<NUM>", (<-debug).MaskedInput)
+
+	assert.Equal(t, "Request <UUID> took <NUM> ms", c.Clusters()[0].MaskedRepresentative)
+}
+
+func TestWithIgnoreAssignReplaces(t *testing.T) {
+	c := NewCounter(2, 0.70).
+		WithIgnoreAssign([]*regexp.Regexp{regexp.MustCompile(`\d+`)}).
+		WithMasks(Mask{Name: "user", Pattern: regexp.MustCompile(`user \w+`), Replacement: "user <USER>"}).
+		WithIgnoreAssign([]*regexp.Regexp{regexp.MustCompile(`[.!]$`)})
+
+	result := c.Assign("user bob logged in 3 times!")
+	assert.Equal(t, []string{"user", "[.!]$"}, result.Masks)
+	assert.Equal(t, "user <USER> logged in 3 times", c.Clusters()[0].MaskedRepresentative)
+}
+
+func TestLoadCounterVersion1(t *testing.T) {
+	// Version 1 snapshots, which predate masks, still load
+	c, err := LoadCounter(strings.NewReader(`{"version": 1, "step": 2, "scoreThreshold": 0.7, "ignorePatterns": ["\\d+"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`\d+`}, c.Assign("error 42").Masks)
+	assert.Equal(t, "error ", c.Clusters()[0].MaskedRepresentative)
+}
@@ -0,0 +1,92 @@
+package snowberry
+
+import "sort"
+
+// Merge re-inserts every cluster of other into c, in the order other created them. A cluster joins the cluster of c its
+// representative scores best against, if the score is above c's threshold, and their counts are summed. Otherwise, it
+// becomes a new cluster of c. The representatives are taken as masked by other, so both Counters should mask alike.
+// History and rates are only merged when both Counters use the same bucket width and half-lives; otherwise the
+// clusters of c keep their own history and rates, without the members of other. Merge returns the ID each cluster of
+// other ended up with in c, which may since have been evicted.
+func (c *Counter) Merge(other *Counter) map[ClusterID]ClusterID {
+	// Copy first, so that neither Counter waits on the other and merging a Counter into itself is possible
+	unlock := other.readLock()
+	clusters := other.clonedClusters()
+	unlock()
+
+	return c.merge(clusters)
+}
+
+// Recluster returns a new Counter with the configuration of c and the provided threshold, holding the clusters of c
+// grouped again under the new threshold. The clusters are re-inserted as by Merge, but largest first, so that the
+// largest cluster of each new group becomes its representative. Members are not kept, so raising the threshold does not
+// split clusters apart, only keeps them from joining each other. c is left unchanged. The debug channel and eviction
+// callback of c are not carried over, and can be set on the new Counter with WithDebugChannel and WithMaxClusters.
+func (c *Counter) Recluster(scoreThreshold float32) *Counter {
+	unlock := c.readLock()
+	r := c.withThreshold(scoreThreshold)
+	clusters := c.clonedClusters()
+	unlock()
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].count > clusters[j].count
+	})
+	r.merge(clusters)
+
+	return r
+}
+
+// clonedClusters returns a copy of every cluster, in order of creation
+func (c *Counter) clonedClusters() []*cluster {
+	clusters := c.sortedClusters()
+	for i, cl := range clusters {
+		clusters[i] = cl.clone()
+	}
+
+	return clusters
+}
+
+// withThreshold returns an empty Counter with the configuration of c and the provided threshold, without the debug
+// channel and eviction callback, which belong to c
+func (c *Counter) withThreshold(scoreThreshold float32) *Counter {
+	r := NewCounter(c.tree.step, scoreThreshold).
+		WithScorer(c.scorer).
+		WithFuzzyTraversal(c.maxKeyEdits).
+		WithExemplars(c.exemplars).
+		WithHistory(c.historyBucket, c.historyRetention).
+		WithRates(c.rateHalfLife, c.baselineHalfLife).
+		WithMaxClusters(c.maxClusters, c.evictionPolicy, nil).
+		WithMasks(c.masks...).
+		WithIgnoreAssign(maskPatterns(c.ignoreMasks)).
+		WithNormalizers(c.normalizers...).
+		WithRejectAssign(c.rejectPatterns)
+	r.tokenKeys = c.tokenKeys
+	r.sharded = c.sharded
+
+	return r
+}
+
+// merge re-inserts the clusters into c in order, see Merge
+func (c *Counter) merge(clusters []*cluster) map[ClusterID]ClusterID {
+	c.lock.Lock()
+
+	ids := make(map[ClusterID]ClusterID, len(clusters))
+	var evicted []*cluster
+	for _, o := range clusters {
+		n := newFruit(o.fruit.original)
+		n.masked, n.masks = o.fruit.masked, o.fruit.masks
+		n.segment(c.tokenKeys)
+
+		cl, _, _ := c.place(c.tree, n, nil)
+		cl.absorb(o)
+		c.touch(cl)
+		evicted = append(evicted, c.evict(cl)...)
+
+		ids[o.fruit.id] = cl.fruit.id
+	}
+
+	c.lock.Unlock()
+	c.reportEvicted(evicted)
+
+	return ids
+}
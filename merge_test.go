@@ -0,0 +1,94 @@
+package snowberry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	a := NewCounter(2, 0.70)
+	snake := a.Assign("There's a snake in my boot.")
+	a.Assign("There's a snail in my boot.")
+	a.Assign("To infinity and beyond!")
+
+	b := NewCounter(2, 0.70)
+	b.Assign("There's a boot in my boot.")
+	b.Assign("There's a snake in my boot.")
+	beyond := b.Assign("To Nanaimo and beyond!")
+	friend := b.Assign("You've got a friend in me.")
+
+	ids := a.Merge(b)
+
+	assert.Equal(t, map[string]int{
+		"There's a snake in my boot.": 4,
+		"To infinity and beyond!":     1,
+		"To Nanaimo and beyond!":      1,
+		"You've got a friend in me.":  1,
+	}, a.Counts())
+
+	assert.Len(t, ids, 3)
+	assert.Equal(t, snake.ID, ids[b.Clusters()[0].ID])
+	assert.Equal(t, beyond.ID, ids[beyond.ID])
+	assert.Equal(t, friend.ID, ids[friend.ID])
+
+	// The merged counter carries on as if it had seen every input
+	assert.Equal(t, friend.ID, a.Assign("You've got a friend in me!").ID)
+
+	// b is left untouched
+	assert.Equal(t, map[string]int{
+		"There's a boot in my boot.": 2,
+		"To Nanaimo and beyond!":     1,
+		"You've got a friend in me.": 1,
+	}, b.Counts())
+}
+
+func TestRecluster(t *testing.T) {
+	c := NewCounter(2, 0.95).WithExemplars(5)
+	c.Assign("There's a snake in my boot.")
+	for i := 0; i < 3; i++ {
+		c.Assign("There's a snail in my boot.")
+	}
+	c.Assign("To infinity and beyond!")
+	c.Assign("To Nanaimo and beyond!")
+
+	before := c.Counts()
+	assert.Len(t, before, 4)
+
+	r := c.Recluster(0.70)
+
+	// The largest cluster represents the new group
+	assert.Equal(t, map[string]int{
+		"There's a snail in my boot.": 4,
+		"To infinity and beyond!":     1,
+		"To Nanaimo and beyond!":      1,
+	}, r.Counts())
+	assert.Equal(t, float32(0.70), r.scoreThreshold)
+	assert.ElementsMatch(t, []string{"There's a snail in my boot.", "There's a snake in my boot."}, r.Clusters()[0].Exemplars)
+
+	// c is left untouched
+	assert.Equal(t, before, c.Counts())
+
+	// Raising the threshold again keeps the groups together
+	assert.Equal(t, r.Counts(), r.Recluster(0.95).Counts())
+}
+
+func TestReclusterLeavesCallbacks(t *testing.T) {
+	debug := make(chan *AssignDebug, 10)
+	evicted := 0
+	c := NewCounter(2, 0.95).
+		WithMaxClusters(1, EvictOldest, func(Cluster) { evicted++ }).
+		WithDebugChannel(debug)
+	c.Assign("There's a snake in my boot.")
+	<-debug
+
+	r := c.Recluster(0.70)
+	r.Assign("To infinity and beyond!")
+	assert.Equal(t, 0, evicted)
+	assert.Len(t, r.Counts(), 1)
+
+	// Closing the new Counter leaves the debug channel of c open
+	r.Close()
+	assert.NotPanics(t, func() { c.Assign("There's a snail in my boot.") })
+	assert.Equal(t, "There's a snail in my boot.", (<-debug).Input)
+}
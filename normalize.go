@@ -0,0 +1,122 @@
+package snowberry
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer rewrites inputs after masking, before they are compared and added to the tree-like index. Normalizers
+// must be safe for concurrent use.
+type Normalizer interface {
+	Normalize(s string) string
+}
+
+// NormalizerFunc adapts an ordinary function to the Normalizer interface
+type NormalizerFunc func(s string) string
+
+// Normalize calls f(s)
+func (f NormalizerFunc) Normalize(s string) string {
+	return f(s)
+}
+
+// NFKCNormalizer applies Unicode NFKC normalization, so that compatibility forms such as full-width letters and
+// ligatures compare equal to their plain forms, and decomposed accents, such as "e" followed by a combining acute
+// accent, compare equal to their precomposed forms. Accents are kept.
+type NFKCNormalizer struct{}
+
+// Normalize implements Normalizer
+func (NFKCNormalizer) Normalize(s string) string {
+	return norm.NFKC.String(s)
+}
+
+// CaseFoldNormalizer applies Unicode case folding, so that strings which differ only in case compare equal
+type CaseFoldNormalizer struct{}
+
+// Normalize implements Normalizer
+func (CaseFoldNormalizer) Normalize(s string) string {
+	// A Caser holds state, so it cannot be shared between concurrent calls
+	return cases.Fold().String(s)
+}
+
+// WhitespaceNormalizer collapses every run of whitespace into a single space, and trims leading and trailing whitespace
+type WhitespaceNormalizer struct{}
+
+// Normalize implements Normalizer
+func (WhitespaceNormalizer) Normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// PunctuationNormalizer removes punctuation. Symbols, such as the angle brackets of mask replacement tokens, are kept.
+type PunctuationNormalizer struct{}
+
+// Normalize implements Normalizer
+func (PunctuationNormalizer) Normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+// StopWordNormalizer removes stop words, words too common to tell inputs apart, such as "the" or "a". Words are
+// separated by whitespace and compared regardless of case and surrounding punctuation. The remaining words are joined
+// by single spaces.
+type StopWordNormalizer struct {
+	words map[string]struct{}
+}
+
+// NewStopWordNormalizer returns a StopWordNormalizer which removes the provided words
+func NewStopWordNormalizer(words ...string) StopWordNormalizer {
+	n := StopWordNormalizer{words: make(map[string]struct{}, len(words))}
+	for _, w := range words {
+		n.words[strings.ToLower(w)] = struct{}{}
+	}
+
+	return n
+}
+
+// Normalize implements Normalizer
+func (n StopWordNormalizer) Normalize(s string) string {
+	fields := strings.Fields(s)
+	kept := fields[:0]
+	for _, f := range fields {
+		if _, ok := n.words[strings.ToLower(strings.TrimFunc(f, unicode.IsPunct))]; !ok {
+			kept = append(kept, f)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
+
+// Words returns the stop words, lower cased, in no particular order
+func (n StopWordNormalizer) Words() []string {
+	words := make([]string, 0, len(n.words))
+	for w := range n.words {
+		words = append(words, w)
+	}
+
+	return words
+}
+
+// WithNormalizers returns a Counter which applies the provided normalizers to assignments, in order, after masking and
+// after any earlier normalizers. The normalized string is what AssignDebug.MaskedInput shows, what reject patterns are
+// matched against, and what is compared.
+func (c *Counter) WithNormalizers(normalizers ...Normalizer) *Counter {
+	c.normalizers = append(c.normalizers, normalizers...)
+
+	return c
+}
+
+// withNormalizers applies the normalizers to the masked string
+func (f *fruit) withNormalizers(normalizers []Normalizer) *fruit {
+	for _, n := range normalizers {
+		f.masked = n.Normalize(f.masked)
+	}
+
+	return f
+}
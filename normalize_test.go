@@ -0,0 +1,55 @@
+package snowberry
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizers(t *testing.T) {
+	for _, tc := range []struct {
+		normalizer      Normalizer
+		input, expected string
+	}{
+		{NFKCNormalizer{}, "ｆｕｌｌ ﬁle Å", "full file Å"},
+		{CaseFoldNormalizer{}, "Straße ERROR", "strasse error"},
+		{WhitespaceNormalizer{}, "  disk \t full\n on  sda1 ", "disk full on sda1"},
+		{PunctuationNormalizer{}, `"disk" full, on <NUM>!`, "disk full on <NUM>"},
+		{NewStopWordNormalizer("a", "The", "on"), "The disk on a host, on sda1", "disk host, sda1"},
+		{NormalizerFunc(strings.ToUpper), "disk full", "DISK FULL"},
+	} {
+		assert.Equal(t, tc.expected, tc.normalizer.Normalize(tc.input), tc.input)
+	}
+}
+
+func TestCounterWithNormalizers(t *testing.T) {
+	debug := make(chan *AssignDebug, 10)
+	c := NewCounter(2, 0.95).
+		WithMasks(MustMaskPresets("number")...).
+		WithNormalizers(
+			NFKCNormalizer{},
+			CaseFoldNormalizer{},
+			PunctuationNormalizer{},
+			NewStopWordNormalizer("the", "a"),
+			WhitespaceNormalizer{},
+		).
+		WithRejectAssign([]*regexp.Regexp{regexp.MustCompile(`^debug`)}).
+		WithDebugChannel(debug)
+
+	// Normalizers apply after masking, so case folding folds replacement tokens too
+	first := c.Assign("The  disk is FULL on volume 1!")
+	assert.Equal(t, "disk is full on volume <num>", (<-debug).MaskedInput)
+
+	second := c.Assign("ＤＩＳＫ is full on a volume 22.")
+	assert.Equal(t, "disk is full on volume <num>", (<-debug).MaskedInput)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, float32(1), second.Score)
+
+	// Reject patterns see the normalized input
+	assert.True(t, c.Assign("DEBUG: flushing").Rejected)
+	d := <-debug
+	assert.True(t, d.Rejected)
+	assert.Equal(t, "debug flushing", d.MaskedInput)
+}
@@ -0,0 +1,129 @@
+package snowberry
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ipv6DigitGroup matches a group of an IPv6 address which contains a digit
+const ipv6DigitGroup = `\d[0-9a-f]{0,3}|[0-9a-f]\d[0-9a-f]{0,2}|[0-9a-f]{2}\d[0-9a-f]?|[0-9a-f]{3}\d`
+
+// maskPresets holds the built-in masks in canonical order, the order they are applied in. Masks of variables which
+// contain other variables come first: URLs contain paths and numbers, timestamps contain numbers, and so on.
+var maskPresets = []Mask{
+	{
+		Name:        "url",
+		Pattern:     regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`),
+		Replacement: "<URL>",
+	},
+	{
+		Name:        "email",
+		Pattern:     regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+		Replacement: "<EMAIL>",
+	},
+	{
+		Name:        "uuid",
+		Pattern:     regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+		Replacement: "<UUID>",
+	},
+	{
+		Name:        "iso8601",
+		Pattern:     regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2}(?:[.,]\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?)?\b`),
+		Replacement: "<TIMESTAMP>",
+	},
+	{
+		Name:        "syslog-timestamp",
+		Pattern:     regexp.MustCompile(`\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) [ \d]\d \d{2}:\d{2}:\d{2}\b`),
+		Replacement: "<TIMESTAMP>",
+	},
+	{
+		// Unix paths at least two levels deep, whose levels do not start with a digit so that dates are left alone, and
+		// Windows paths
+		Name:        "path",
+		Pattern:     regexp.MustCompile(`(?:~|\.{1,2}|\b[\w.-]+)?(?:/[A-Za-z_.][\w.@+-]*){2,}|\b[A-Za-z]:\\[^\s"'<>|:*?]*`),
+		Replacement: "<PATH>",
+	},
+	{
+		// Full and compressed forms. So that scope operators such as "std::map" and "a::b" are left alone, a compressed
+		// form needs a group with a digit before a trailing "::", and a full group or a group with a digit at its end.
+		Name: "ipv6",
+		Pattern: regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|` +
+			`\b(?:[0-9a-f]{1,4}:){1,6}(?::[0-9a-f]{1,4}){0,5}:(?:[0-9a-f]{4}|` + ipv6DigitGroup + `)\b|` +
+			`\b(?:[0-9a-f]{1,4}:){0,6}(?:` + ipv6DigitGroup + `)::|` +
+			`::(?:[0-9a-f]{1,4}:){0,6}(?:[0-9a-f]{4}|` + ipv6DigitGroup + `)\b`),
+		Replacement: "<IP>",
+	},
+	{
+		Name:        "ipv4",
+		Pattern:     regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+		Replacement: "<IP>",
+	},
+	{
+		// Versions with at least three parts, such as "1.21.0", or prefixed with a v, such as "v1.2". Applied after IPv4
+		// addresses, which look like four part versions.
+		Name:        "version",
+		Pattern:     regexp.MustCompile(`\bv\d+(?:\.\d+)+\b|\b\d+(?:\.\d+){2,}\b`),
+		Replacement: "<VERSION>",
+	},
+	{
+		// Single and compound units, such as "150ms", "1h30m" and "5 minutes". Only spelled out units may follow a
+		// space, so that "4 h" and "10 d" are left to the number mask.
+		Name: "duration",
+		Pattern: regexp.MustCompile(`(?i)\b(?:\d+(?:\.\d+)?(?:\s?(?:nanoseconds?|microseconds?|milliseconds?|seconds?|minutes?|` +
+			`hours?|days?|secs?|mins?|hrs?)|ns|us|µs|ms|[smhd]))+\b`),
+		Replacement: "<DURATION>",
+	},
+	{
+		Name:        "number",
+		Pattern:     regexp.MustCompile(`\b\d+(?:\.\d+)?\b`),
+		Replacement: "<NUM>",
+	},
+	{
+		// Prefixed hex numbers, and hashes and addresses of at least 8 hex digits. Applied after numbers, so that long
+		// decimal numbers stay numbers.
+		Name:        "hex",
+		Pattern:     regexp.MustCompile(`\b(?:0[xX][0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`),
+		Replacement: "<HEX>",
+	},
+}
+
+// MaskPresets returns the built-in masks with the provided names, for use with WithMasks, or every built-in mask when
+// no names are provided. Whatever order the names are in, the masks are returned in canonical order, which applies the
+// masks of variables that contain other variables first. The names are url, email, uuid, iso8601, syslog-timestamp,
+// path, ipv6, ipv4, version, duration, number and hex.
+func MaskPresets(names ...string) ([]Mask, error) {
+	if len(names) == 0 {
+		return append([]Mask(nil), maskPresets...), nil
+	}
+
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+
+	var masks []Mask
+	for _, m := range maskPresets {
+		if selected[m.Name] {
+			masks = append(masks, m)
+			delete(selected, m.Name)
+		}
+	}
+
+	for _, name := range names {
+		if selected[name] {
+			return nil, fmt.Errorf("snowberry: unknown mask preset %q", name)
+		}
+	}
+
+	return masks, nil
+}
+
+// MustMaskPresets is like MaskPresets but panics if a name is unknown
+func MustMaskPresets(names ...string) []Mask {
+	masks, err := MaskPresets(names...)
+	if err != nil {
+		panic(err)
+	}
+
+	return masks
+}
@@ -0,0 +1,74 @@
+package snowberry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskPresets(t *testing.T) {
+	presets := MustMaskPresets()
+
+	for _, tc := range []struct {
+		input, masked string
+		masks         []string
+	}{
+		{"GET https://example.com/a/b?id=42&x=y failed", "GET <URL> failed", []string{"url"}},
+		{"mail to jane.doe+ops@example.co.uk bounced", "mail to <EMAIL> bounced", []string{"email"}},
+		{"job 123e4567-e89b-12d3-a456-426614174000 done", "job <UUID> done", []string{"uuid"}},
+		{"2024-09-08T23:30:03.333Z started", "<TIMESTAMP> started", []string{"iso8601"}},
+		{"at 2024-09-08 23:30:03+02:00 and 2024-09-09", "at <TIMESTAMP> and <TIMESTAMP>", []string{"iso8601"}},
+		{"Sep  8 23:30:03 host sshd", "<TIMESTAMP> host sshd", []string{"syslog-timestamp"}},
+		{"open /var/log/app-2.log: denied", "open <PATH>: denied", []string{"path"}},
+		{`open C:\Users\bob\file.txt denied`, "open <PATH> denied", []string{"path"}},
+		{"GET /api/v1/users/123", "GET <PATH>/<NUM>", []string{"path", "number"}},
+		{"on 09/08/2024 and/or later", "on <NUM>/<NUM>/<NUM> and/or later", []string{"number"}},
+		{"from fe80::1ff:fe23:4567:890a to ::1", "from <IP> to <IP>", []string{"ipv6"}},
+		{"from 2001:0db8:85a3:0000:0000:8a2e:0370:7334", "from <IP>", []string{"ipv6"}},
+		{"std::vector at 12:30:45", "std::vector at <NUM>:<NUM>:<NUM>", []string{"number"}},
+		{"Face::render failed", "Face::render failed", nil},
+		{"call add::apply", "call add::apply", nil},
+		{"a::b", "a::b", nil},
+		{"bind 2001:db8:: and ::ffff", "bind <IP> and <IP>", []string{"ipv6"}},
+		{"peer 192.168.0.1:8080 reset", "peer <IP>:<NUM> reset", []string{"ipv4", "number"}},
+		{"took 150ms, then 1h30m, then 5 minutes", "took <DURATION>, then <DURATION>, then <DURATION>", []string{"duration"}},
+		{"read 10 d records", "read <NUM> d records", []string{"number"}},
+		{"build 4 h ago, 3 hrs later", "build <NUM> h ago, <DURATION> later", []string{"duration", "number"}},
+		{"upgrade v1.2.3 to 1.21.0 from v2.0", "upgrade <VERSION> to <VERSION> from <VERSION>", []string{"version"}},
+		{"retry 3 of 5 in 2.5s", "retry <NUM> of <NUM> in <DURATION>", []string{"duration", "number"}},
+		{"fault at 0x7ffe3b2c hash 9f86d081884c7d65", "fault at <HEX> hash <HEX>", []string{"hex"}},
+		{"epoch 1725838203 and 3 items", "epoch <NUM> and <NUM> items", []string{"number"}},
+		{"nothing to see here", "nothing to see here", nil},
+	} {
+		f := newFruit(tc.input).withMasks(presets)
+		assert.Equal(t, tc.masked, f.masked, tc.input)
+		assert.Equal(t, tc.masks, f.masks, tc.input)
+	}
+}
+
+func TestMaskPresetsSelection(t *testing.T) {
+	// Canonical order, whatever the order of the names
+	masks, err := MaskPresets("number", "ipv4", "iso8601")
+	assert.NoError(t, err)
+	var names []string
+	for _, m := range masks {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"iso8601", "ipv4", "number"}, names)
+
+	_, err = MaskPresets("ipv4", "ipv5")
+	assert.ErrorContains(t, err, `unknown mask preset "ipv5"`)
+	assert.Panics(t, func() { MustMaskPresets("ipv5") })
+
+	c := NewCounter(3, 0.90).WithMasks(MustMaskPresets()...)
+	for _, s := range []string{
+		"2024-09-08T23:30:03Z connection from 10.0.0.1 closed after 35ms",
+		"2024-09-08T23:31:17Z connection from 10.0.0.254 closed after 1.5s",
+		"2024-09-09T01:02:03Z connection from fe80::1 closed after 2 minutes",
+	} {
+		c.Assign(s)
+	}
+	assert.Equal(t, map[string]int{
+		"2024-09-08T23:30:03Z connection from 10.0.0.1 closed after 35ms": 3,
+	}, c.Counts())
+}
@@ -0,0 +1,121 @@
+package snowberry
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// rates tracks exponentially decayed rates of events, over a short half-life for the current rate and a long
+// half-life for the baseline
+type rates struct {
+	// decay holds ln(2) divided by the half-lives, in seconds. A baselineDecay of 0 tracks no baseline.
+	decay, baselineDecay float64
+	// rate and baseline are in events per second, as of at
+	rate, baseline float64
+	at             time.Time
+}
+
+func newRates(halfLife, baselineHalfLife time.Duration) *rates {
+	r := &rates{decay: math.Ln2 / halfLife.Seconds()}
+	if baselineHalfLife > 0 {
+		r.baselineDecay = math.Ln2 / baselineHalfLife.Seconds()
+	}
+
+	return r
+}
+
+// add counts n events at the provided time. Events older than the latest event count as already decayed.
+func (r *rates) add(at time.Time, n int) {
+	r.addRates(at, float64(n)*r.decay, float64(n)*r.baselineDecay)
+}
+
+// remove uncounts n events at the provided time, never dropping below zero
+func (r *rates) remove(at time.Time, n int) {
+	r.addRates(at, -float64(n)*r.decay, -float64(n)*r.baselineDecay)
+	r.rate, r.baseline = max(r.rate, 0), max(r.baseline, 0)
+}
+
+func (r *rates) addRates(at time.Time, rate, baseline float64) {
+	if at.After(r.at) {
+		r.rate, r.baseline = r.current(at)
+		r.at = at
+	} else {
+		elapsed := r.at.Sub(at).Seconds()
+		rate *= math.Exp(-r.decay * elapsed)
+		baseline *= math.Exp(-r.baselineDecay * elapsed)
+	}
+
+	r.rate += rate
+	r.baseline += baseline
+}
+
+// current returns the rate and baseline decayed to the provided time
+func (r *rates) current(at time.Time) (float64, float64) {
+	if !at.After(r.at) {
+		return r.rate, r.baseline
+	}
+
+	elapsed := at.Sub(r.at).Seconds()
+
+	return r.rate * math.Exp(-r.decay*elapsed), r.baseline * math.Exp(-r.baselineDecay*elapsed)
+}
+
+// merge adds the events of other, which must have the same half-lives
+func (r *rates) merge(other *rates) {
+	r.addRates(other.at, other.rate, other.baseline)
+}
+
+func (r *rates) clone() *rates {
+	clone := *r
+
+	return &clone
+}
+
+// ClusterRate is the rate at which members of a category occur
+type ClusterRate struct {
+	ID             ClusterID
+	Representative string
+	// Rate is the exponentially decayed rate, in members per second, over the half-life
+	Rate float64
+	// Baseline is the exponentially decayed rate, in members per second, over the baseline half-life
+	Baseline float64
+	// Increase is Rate less Baseline
+	Increase float64
+}
+
+// HotClusters returns the `n` categories with the highest rate at the provided time, highest first. It returns nothing
+// unless the Counter tracks rates.
+func (c *Counter) HotClusters(at time.Time, n int) []ClusterRate {
+	return c.rankRates(at, n, func(a, b ClusterRate) bool { return a.Rate > b.Rate })
+}
+
+// RisingClusters returns the `n` categories whose rate at the provided time rose the most above their baseline,
+// most first. It returns nothing unless the Counter tracks rates.
+func (c *Counter) RisingClusters(at time.Time, n int) []ClusterRate {
+	return c.rankRates(at, n, func(a, b ClusterRate) bool { return a.Increase > b.Increase })
+}
+
+func (c *Counter) rankRates(at time.Time, n int, less func(a, b ClusterRate) bool) []ClusterRate {
+	defer c.readLock()()
+
+	var ranked []ClusterRate
+	for _, cl := range c.sortedClusters() {
+		if cl.rates == nil {
+			continue
+		}
+
+		rate, baseline := cl.rates.current(at)
+		ranked = append(ranked, ClusterRate{
+			ID:             cl.fruit.id,
+			Representative: cl.fruit.original,
+			Rate:           rate,
+			Baseline:       baseline,
+			Increase:       rate - baseline,
+		})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return less(ranked[i], ranked[j]) })
+
+	return ranked[:min(max(n, 0), len(ranked))]
+}
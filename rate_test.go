@@ -0,0 +1,88 @@
+package snowberry
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRates(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+
+	c := NewCounter(5, 0.60).WithRates(time.Minute, time.Hour)
+	// A steady trickle of failed logins over the last hour
+	for i := 0; i < 60; i++ {
+		c.AssignAt("User alice failed login", at.Add(time.Duration(i-60)*time.Minute))
+	}
+	// A sudden burst of disk errors
+	for i := 0; i < 30; i++ {
+		c.AssignAt("Disk sda1 is full", at.Add(time.Duration(i-30)*time.Second))
+	}
+
+	hot := c.HotClusters(at, 10)
+	assert.Len(t, hot, 2)
+	assert.Equal(t, "Disk sda1 is full", hot[0].Representative)
+	assert.Equal(t, "User alice failed login", hot[1].Representative)
+
+	rising := c.RisingClusters(at, 1)
+	assert.Len(t, rising, 1)
+	assert.Equal(t, "Disk sda1 is full", rising[0].Representative)
+	assert.Greater(t, rising[0].Increase, float64(0))
+	assert.InDelta(t, rising[0].Rate-rising[0].Baseline, rising[0].Increase, 1e-9)
+
+	// One member per minute settles on a rate of about one per minute
+	assert.InDelta(t, 1.0/60, hot[1].Rate, 0.006)
+
+	// An hour later the burst has decayed away
+	c.AssignAt("User bob failed login", at.Add(time.Hour))
+	later := c.HotClusters(at.Add(time.Hour), 1)
+	assert.Equal(t, "User alice failed login", later[0].Representative)
+
+	// Rates halve every half-life
+	assert.InDelta(t, later[0].Rate/2, c.HotClusters(at.Add(time.Hour+time.Minute), 1)[0].Rate, 1e-9)
+
+	assert.Empty(t, NewCounter(5, 0.60).HotClusters(at, 10))
+}
+
+func TestRatesOutOfOrder(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+
+	ordered := newRates(time.Minute, time.Hour)
+	ordered.add(at, 1)
+	ordered.add(at.Add(time.Minute), 1)
+
+	unordered := newRates(time.Minute, time.Hour)
+	unordered.add(at.Add(time.Minute), 1)
+	unordered.add(at, 1)
+
+	merged := newRates(time.Minute, time.Hour)
+	merged.add(at.Add(time.Minute), 1)
+	other := newRates(time.Minute, time.Hour)
+	other.add(at, 1)
+	merged.merge(other)
+
+	for _, r := range []*rates{unordered, merged} {
+		rate, baseline := r.current(at.Add(time.Hour))
+		expectedRate, expectedBaseline := ordered.current(at.Add(time.Hour))
+		assert.InDelta(t, expectedRate, rate, 1e-12)
+		assert.InDelta(t, expectedBaseline, baseline, 1e-12)
+	}
+}
+
+func TestRatesWithoutBaseline(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+
+	c := NewCounter(5, 0.60).WithRates(time.Minute, 0)
+	c.AssignAt("User alice failed login", at)
+
+	rising := c.RisingClusters(at.Add(time.Minute), 1)
+	assert.Len(t, rising, 1)
+	assert.Equal(t, float64(0), rising[0].Baseline)
+	assert.InDelta(t, math.Ln2/60/2, rising[0].Rate, 1e-12)
+	assert.Equal(t, rising[0].Rate, rising[0].Increase)
+
+	assert.NoError(t, c.SnapshotJSON(io.Discard))
+}
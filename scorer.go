@@ -0,0 +1,450 @@
+package snowberry
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scorer calculates how similar two strings are. Implementations must return a value E [0..1], where 1 represents a
+// perfect match, so that a Counter's scoreThreshold keeps the same meaning regardless of the Scorer in use.
+// Implementations must be safe for concurrent use, as AssignBatch and sharded Counters call Score from several
+// goroutines at once.
+type Scorer interface {
+	Score(a, b string) float32
+}
+
+// BoundedScorer is a Scorer which can give up early on pairs which are certain not to score above a floor. Counter uses
+// it, when available, to skip the bulk of the work for candidates which cannot beat the threshold or the best match so
+// far.
+type BoundedScorer interface {
+	Scorer
+	// ScoreAbove returns the same score as Score and true when that score is above floor, otherwise false.
+	ScoreAbove(a, b string, floor float32) (float32, bool)
+}
+
+// ScorerFunc adapts an ordinary function to the Scorer interface
+type ScorerFunc func(a, b string) float32
+
+// Score calls f(a, b)
+func (f ScorerFunc) Score(a, b string) float32 {
+	return f(a, b)
+}
+
+// LevenshteinScorer scores by levenshtein distance, normalized by the rune length of the longer string. This is the
+// default.
+type LevenshteinScorer struct{}
+
+// Score implements Scorer
+func (LevenshteinScorer) Score(a, b string) float32 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	return float32(longest-levenshteinDistance(ra, rb)) / float32(longest)
+}
+
+// ScoreAbove implements BoundedScorer. The floor is translated into a maximum distance, which rules out pairs by length
+// alone and lets the distance calculation stop as soon as it is exceeded.
+func (l LevenshteinScorer) ScoreAbove(a, b string, floor float32) (float32, bool) {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1, 1 > floor
+	}
+
+	score := func(distance int) float32 {
+		return float32(longest-distance) / float32(longest)
+	}
+
+	// Find the largest distance which still scores above the floor, using the same arithmetic as Score.
+	maxDistance := longest - int(math.Floor(float64(floor)*float64(longest))) - 1
+	for maxDistance >= 0 && score(maxDistance) <= floor {
+		maxDistance--
+	}
+	for maxDistance < longest && score(maxDistance+1) > floor {
+		maxDistance++
+	}
+	if maxDistance < 0 {
+		return 0, false
+	}
+
+	distance, ok := boundedLevenshteinDistance(ra, rb, maxDistance)
+	if !ok {
+		return 0, false
+	}
+
+	return score(distance), true
+}
+
+// levenshteinDistance returns the levenshtein distance between two rune slices. Unlike the package level table used by
+// github.com/ka-weihe/fast-levenshtein, all state is local, so it is safe for concurrent use and accepts any rune.
+func levenshteinDistance(a, b []rune) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(b) == 0 {
+		return len(a)
+	}
+	if len(b) <= 64 {
+		return myersDistance(a, b)
+	}
+
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			diagonal, row[j] = row[j], min(row[j]+1, row[j-1]+1, diagonal+cost)
+		}
+	}
+
+	return row[len(b)]
+}
+
+// boundedLevenshteinDistance returns the levenshtein distance between two rune slices and true, or false as soon as the
+// distance is certain to exceed maxDistance.
+func boundedLevenshteinDistance(a, b []rune, maxDistance int) (int, bool) {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a)-len(b) > maxDistance {
+		return 0, false
+	}
+
+	// A shared prefix or suffix never adds to the distance
+	for len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	if len(b) == 0 {
+		return len(a), len(a) <= maxDistance
+	}
+	if len(b) <= 64 {
+		return boundedMyersDistance(a, b, maxDistance)
+	}
+
+	// Only cells within maxDistance of the diagonal can lead to a distance within maxDistance, so the rest of the matrix
+	// is treated as out of bounds.
+	outOfBounds := maxDistance + 1
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = min(j, outOfBounds)
+	}
+
+	for i := 1; i <= len(a); i++ {
+		lo, hi := max(1, i-maxDistance), min(len(b), i+maxDistance)
+		cur[lo-1] = outOfBounds
+		if lo == 1 {
+			cur[0] = min(i, outOfBounds)
+		}
+
+		rowMin := cur[lo-1]
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost, outOfBounds)
+			rowMin = min(rowMin, cur[j])
+		}
+		if hi < len(b) {
+			cur[hi+1] = outOfBounds
+		}
+
+		// Distances never decrease along a diagonal, so there is no way back within bounds.
+		if rowMin > maxDistance {
+			return 0, false
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)], prev[len(b)] <= maxDistance
+}
+
+// myersDistance is the bit-parallel levenshtein distance of Myers (1999), for patterns of no more than 64 runes
+func myersDistance(text, pattern []rune) int {
+	distance, _ := boundedMyersDistance(text, pattern, len(text)+len(pattern))
+
+	return distance
+}
+
+// boundedMyersDistance is myersDistance, which returns false as soon as the distance is certain to exceed maxDistance
+func boundedMyersDistance(text, pattern []rune, maxDistance int) (int, bool) {
+	var ascii [128]uint64
+	var other map[rune]uint64
+	for i, r := range pattern {
+		if r < 128 {
+			ascii[r] |= 1 << i
+			continue
+		}
+		if other == nil {
+			other = make(map[rune]uint64)
+		}
+		other[r] |= 1 << i
+	}
+
+	pv, mv := ^uint64(0), uint64(0)
+	last := uint64(1) << (len(pattern) - 1)
+	distance := len(pattern)
+	for i, r := range text {
+		var eq uint64
+		if r < 128 {
+			eq = ascii[r]
+		} else {
+			eq = other[r]
+		}
+
+		xv := eq | mv
+		xh := (((eq & pv) + pv) ^ pv) | eq
+		ph := mv | ^(xh | pv)
+		mh := pv & xh
+		if ph&last != 0 {
+			distance++
+		}
+		if mh&last != 0 {
+			distance--
+		}
+
+		// Each remaining rune can lower the distance by one at most
+		if distance-(len(text)-i-1) > maxDistance {
+			return 0, false
+		}
+
+		ph = (ph << 1) | 1
+		mh <<= 1
+		pv = mh | ^(xv | ph)
+		mv = ph & xv
+	}
+
+	return distance, distance <= maxDistance
+}
+
+// DamerauLevenshteinScorer scores by optimal string alignment distance, which counts the transposition of two adjacent
+// characters as a single edit. Normalized by the length of the longer string.
+type DamerauLevenshteinScorer struct{}
+
+// Score implements Scorer
+func (DamerauLevenshteinScorer) Score(a, b string) float32 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	return float32(longest-osaDistance(ra, rb)) / float32(longest)
+}
+
+// osaDistance returns the optimal string alignment distance between two rune slices
+func osaDistance(a, b []rune) int {
+	// Three rolling rows are enough, since a transposition only looks back two rows.
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
+
+// JaroWinklerScorer scores by Jaro-Winkler similarity, which favours strings sharing a common prefix
+type JaroWinklerScorer struct{}
+
+// Score implements Scorer
+func (JaroWinklerScorer) Score(a, b string) float32 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		lo, hi := max(0, i-window), min(len(rb), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	// Count matched characters which appear in a different order
+	transpositions, j := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+
+	return float32(jaro + float64(prefix)*0.1*(1-jaro))
+}
+
+// TokenSetRatioScorer splits strings into words and compares the shared words and the differing words separately, so
+// that word order and repeated words have no effect on the score
+type TokenSetRatioScorer struct{}
+
+// Score implements Scorer
+func (TokenSetRatioScorer) Score(a, b string) float32 {
+	ta, tb := tokenSet(a), tokenSet(b)
+
+	var shared, onlyA, onlyB []string
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared = append(shared, t)
+		} else {
+			onlyA = append(onlyA, t)
+		}
+	}
+	for t := range tb {
+		if _, ok := ta[t]; !ok {
+			onlyB = append(onlyB, t)
+		}
+	}
+
+	sort.Strings(shared)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+
+	base := strings.Join(shared, " ")
+	withA := strings.TrimSpace(base + " " + strings.Join(onlyA, " "))
+	withB := strings.TrimSpace(base + " " + strings.Join(onlyB, " "))
+
+	var l LevenshteinScorer
+	best := l.Score(withA, withB)
+	if len(shared) > 0 {
+		best = max(best, l.Score(base, withA), l.Score(base, withB))
+	}
+
+	return best
+}
+
+// tokenSet returns the set of words in s, split on anything that is not a letter or a number
+func tokenSet(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		set[t] = struct{}{}
+	}
+
+	return set
+}
+
+// NGramCosineScorer scores by the cosine similarity of the character n-gram frequencies of two strings. N defaults to 3.
+type NGramCosineScorer struct {
+	N int
+}
+
+// Score implements Scorer
+func (s NGramCosineScorer) Score(a, b string) float32 {
+	if a == b {
+		return 1
+	}
+
+	n := s.N
+	if n <= 0 {
+		n = 3
+	}
+
+	ga, gb := nGrams(a, n), nGrams(b, n)
+
+	var dot, normA, normB float64
+	for g, ca := range ga {
+		dot += float64(ca * gb[g])
+		normA += float64(ca * ca)
+	}
+	for _, cb := range gb {
+		normB += float64(cb * cb)
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// nGrams counts the n-grams of s. Strings shorter than n count as a single gram.
+func nGrams(s string, n int) map[string]int {
+	r := []rune(s)
+	grams := make(map[string]int)
+	if len(r) == 0 {
+		return grams
+	}
+	if len(r) < n {
+		grams[s]++
+		return grams
+	}
+
+	for i := 0; i+n <= len(r); i++ {
+		grams[string(r[i:i+n])]++
+	}
+
+	return grams
+}
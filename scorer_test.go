@@ -0,0 +1,144 @@
+package snowberry
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScorers(t *testing.T) {
+	scorers := map[string]Scorer{
+		"levenshtein":         LevenshteinScorer{},
+		"damerau-levenshtein": DamerauLevenshteinScorer{},
+		"jaro-winkler":        JaroWinklerScorer{},
+		"token-set-ratio":     TokenSetRatioScorer{},
+		"n-gram-cosine":       NGramCosineScorer{},
+	}
+
+	pairs := [][2]string{
+		{"", ""},
+		{"", "abc"},
+		{"abc", "abc"},
+		{"abc", "xyz"},
+		{"kitten", "sitting"},
+		{"There's a snake in my boot.", "There's a snail in my boot."},
+		{"the quick brown fox", "fox brown quick the"},
+	}
+
+	for name, s := range scorers {
+		for _, p := range pairs {
+			score := s.Score(p[0], p[1])
+			assert.GreaterOrEqual(t, score, float32(0), name, p)
+			assert.LessOrEqual(t, score, float32(1), name, p)
+			assert.Equal(t, score, s.Score(p[1], p[0]), name, p)
+
+			if p[0] == p[1] {
+				assert.Equal(t, float32(1), score, name, p)
+			}
+		}
+	}
+
+	assert.InDelta(t, float32(4)/7, LevenshteinScorer{}.Score("kitten", "sitting"), 0.0001)
+	assert.InDelta(t, float32(3)/4, DamerauLevenshteinScorer{}.Score("abcd", "abdc"), 0.0001)
+	assert.InDelta(t, float32(2)/4, LevenshteinScorer{}.Score("abcd", "abdc"), 0.0001)
+	assert.InDelta(t, 0.9611, JaroWinklerScorer{}.Score("MARTHA", "MARHTA"), 0.0001)
+	assert.Equal(t, float32(1), TokenSetRatioScorer{}.Score("the quick brown fox", "fox brown quick the"))
+	assert.Equal(t, float32(0), NGramCosineScorer{N: 2}.Score("abc", "xyz"))
+}
+
+func TestCounterWithScorer(t *testing.T) {
+	c := NewCounter(2, 0.90).WithScorer(TokenSetRatioScorer{})
+
+	for _, s := range []string{
+		"disk full on volume data",
+		"disk full on data volume",
+		"volume data disk full on",
+		"network unreachable",
+	} {
+		c.Assign(s)
+	}
+
+	assert.Equal(t, map[string]int{
+		"disk full on volume data": 3,
+		"network unreachable":      1,
+	}, c.Counts())
+}
+
+func TestLevenshteinDistance(t *testing.T) {
+	naive := func(a, b []rune) int {
+		d := make([][]int, len(a)+1)
+		for i := range d {
+			d[i] = make([]int, len(b)+1)
+			d[i][0] = i
+		}
+		for j := range d[0] {
+			d[0][j] = j
+		}
+		for i := 1; i <= len(a); i++ {
+			for j := 1; j <= len(b); j++ {
+				cost := 1
+				if a[i-1] == b[j-1] {
+					cost = 0
+				}
+				d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			}
+		}
+
+		return d[len(a)][len(b)]
+	}
+
+	r := rand.New(rand.NewSource(1))
+	alphabet := []rune("abcé日本🔥")
+	randomRunes := func(n int) []rune {
+		s := make([]rune, n)
+		for i := range s {
+			s[i] = alphabet[r.Intn(len(alphabet))]
+		}
+
+		return s
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomRunes(r.Intn(100)), randomRunes(r.Intn(100))
+		assert.Equal(t, naive(a, b), levenshteinDistance(a, b), "%q %q", string(a), string(b))
+	}
+}
+
+func TestLevenshteinScoreAbove(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	alphabet := []rune("ab日🔥")
+	randomString := func(n int) string {
+		s := make([]rune, n)
+		for i := range s {
+			s[i] = alphabet[r.Intn(len(alphabet))]
+		}
+
+		return string(s)
+	}
+
+	var l LevenshteinScorer
+	for i := 0; i < 2000; i++ {
+		a := randomString(r.Intn(150))
+		b := a
+		if r.Intn(2) == 0 {
+			b = randomString(r.Intn(150))
+		} else {
+			// Mutate a few runes, so that most pairs land near the floor
+			rb := []rune(b)
+			for j := 0; j < r.Intn(10) && len(rb) > 0; j++ {
+				rb[r.Intn(len(rb))] = alphabet[r.Intn(len(alphabet))]
+			}
+			b = string(rb)
+		}
+		floor := r.Float32()
+
+		expected := l.Score(a, b)
+		score, ok := l.ScoreAbove(a, b, floor)
+
+		assert.Equal(t, expected > floor, ok, "%q %q %v", a, b, floor)
+		if ok {
+			assert.Equal(t, expected, score, "%q %q %v", a, b, floor)
+		}
+	}
+}
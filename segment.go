@@ -0,0 +1,104 @@
+package snowberry
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const zeroWidthJoiner = '\u200d'
+
+// graphemeBounds returns the byte offsets at which each user-perceived character of s begins, followed by len(s).
+// Combining marks, variation selectors, emoji modifiers, zero width joiner sequences and regional indicator pairs are
+// kept together with the character they modify, so that slicing between two bounds never produces invalid UTF-8 or a
+// dangling accent.
+func graphemeBounds(s string) []int {
+	bounds := make([]int, 0, utf8.RuneCountInString(s)+1)
+
+	var prev rune
+	regionalIndicators := 0
+	for i, r := range s {
+		if i > 0 && extendsGrapheme(prev, r, regionalIndicators) {
+			if isRegionalIndicator(r) {
+				regionalIndicators++
+			}
+			prev = r
+			continue
+		}
+
+		bounds = append(bounds, i)
+		regionalIndicators = 0
+		if isRegionalIndicator(r) {
+			regionalIndicators = 1
+		}
+		prev = r
+	}
+
+	return append(bounds, len(s))
+}
+
+// extendsGrapheme reports whether r belongs to the same grapheme cluster as the preceding rune prev
+func extendsGrapheme(prev, r rune, regionalIndicators int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == zeroWidthJoiner, r == zeroWidthJoiner:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // emoji skin tone modifiers
+		return true
+	case r >= 0xe0020 && r <= 0xe007f: // tag sequences, as used by subdivision flags
+		return true
+	case r >= 0x1160 && r <= 0x11ff: // hangul medial vowels and final consonants
+		return true
+	case isRegionalIndicator(r):
+		return regionalIndicators%2 == 1
+	}
+
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// tokenBounds returns the byte offsets at which each token of s begins, followed by len(s). A token is a run of
+// letters, numbers and connecting marks, or a single grapheme cluster of punctuation or symbols. Whitespace is kept with
+// the token it follows, and leading whitespace with the first token.
+func tokenBounds(s string) []int {
+	graphemes := graphemeBounds(s)
+	bounds := []int{0}
+
+	inWord, sawToken := false, false
+	for i := 0; i < len(graphemes)-1; i++ {
+		r, _ := utf8.DecodeRuneInString(s[graphemes[i]:])
+
+		switch {
+		case unicode.IsSpace(r):
+			inWord = false
+			continue
+		case isWordRune(r):
+			if inWord {
+				continue
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+
+		if sawToken {
+			bounds = append(bounds, graphemes[i])
+		}
+		sawToken = true
+	}
+
+	if !sawToken {
+		return bounds
+	}
+
+	return append(bounds, len(s))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+}
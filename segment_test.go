@@ -0,0 +1,121 @@
+package snowberry
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphemeBounds(t *testing.T) {
+	for s, expected := range map[string][]string{
+		"":                            {},
+		"abc":                         {"a", "b", "c"},
+		"caf\u00e9":                   {"c", "a", "f", "\u00e9"},
+		"cafe\u0301":                  {"c", "a", "f", "e\u0301"},
+		"日本語":                         {"日", "本", "語"},
+		"\U0001f44d\U0001f3fdok":      {"\U0001f44d\U0001f3fd", "o", "k"},
+		"\U0001f469\u200d\U0001f4bb!": {"\U0001f469\u200d\U0001f4bb", "!"},
+		"\U0001f1e8\U0001f1e6\U0001f1eb\U0001f1f7": {"\U0001f1e8\U0001f1e6", "\U0001f1eb\U0001f1f7"},
+		"x\ufe0f\u20e3yz":                          {"x\ufe0f\u20e3", "y", "z"},
+		"line\r\nbreak":                            {"l", "i", "n", "e", "\r\n", "b", "r", "e", "a", "k"},
+		"Привет, мир":                              {"П", "р", "и", "в", "е", "т", ",", " ", "м", "и", "р"},
+	} {
+		bounds := graphemeBounds(s)
+		actual := make([]string, 0, len(bounds)-1)
+		for i := 0; i < len(bounds)-1; i++ {
+			segment := s[bounds[i]:bounds[i+1]]
+			assert.True(t, utf8.ValidString(segment), s)
+			actual = append(actual, segment)
+		}
+
+		assert.Equal(t, expected, actual, s)
+	}
+}
+
+func TestCounterMixedScript(t *testing.T) {
+	f := []string{
+		"Ошибка подключения к базе данных 1",
+		"Ошибка подключения к базе данных 2",
+		"データベースへの接続に失敗しました",
+		"データベースへの接続に失敗しました！",
+		"Échec de connexion à la base de données",
+		"Echec de connexion a la base de donnees",
+		"🔥 disk full on /var 🔥",
+		"🔥 disk full on /tmp 🔥",
+		"Database connection failed",
+	}
+
+	c := NewCounter(3, 0.80)
+	for _, s := range f {
+		debug := make(chan *AssignDebug, 1)
+		c.WithDebugChannel(debug).Assign(s)
+
+		d := <-debug
+		assert.GreaterOrEqual(t, d.BestMatchScore, float32(0), s)
+		assert.LessOrEqual(t, d.BestMatchScore, float32(1), s)
+	}
+
+	assert.Equal(t, map[string]int{
+		"Ошибка подключения к базе данных 1":      2,
+		"データベースへの接続に失敗しました":                       2,
+		"Échec de connexion à la base de données": 2,
+		"🔥 disk full on /var 🔥":                   2,
+		"Database connection failed":              1,
+	}, c.Counts())
+
+	var walk func(b *branch)
+	walk = func(b *branch) {
+		for key, child := range b.branches {
+			assert.True(t, utf8.ValidString(key), key)
+			assert.Equal(t, 3, utf8.RuneCountInString(key), key)
+			walk(child)
+		}
+	}
+	walk(c.tree)
+}
+
+func TestTokenBounds(t *testing.T) {
+	for s, expected := range map[string][]string{
+		"":                          {},
+		"   ":                       {},
+		"User alice failed login.":  {"User", "alice", "failed", "login", "."},
+		"  id=42,  retry_count=3 ":  {"id", "=", "42", ",", "retry_count", "=", "3"},
+		"Échec de connexion à 日本語!": {"Échec", "de", "connexion", "à", "日本語", "!"},
+		"🔥🔥 hot":                    {"🔥", "🔥", "hot"},
+	} {
+		f := newFruit(s).segment(true)
+
+		actual := make([]string, 0, f.length())
+		for i := 0; i < f.length(); i++ {
+			actual = append(actual, f.key(i, i+1))
+		}
+
+		assert.Equal(t, expected, actual, s)
+		assert.Equal(t, s, f.tail(0), s)
+	}
+}
+
+func TestCounterWithTokenKeys(t *testing.T) {
+	c := NewCounter(1, 0.70).WithTokenKeys()
+
+	for _, s := range []string{
+		"User alice failed login from 10.0.0.1",
+		"User bartholomew failed login from 10.0.0.2",
+		"User bob logged out",
+		"Disk full on /var",
+	} {
+		c.Assign(s)
+	}
+
+	user, ok := c.tree.branches["User"]
+	assert.True(t, ok)
+	assert.Len(t, user.allDescendantFruit(), 2)
+	assert.Contains(t, c.tree.branches, "Disk")
+
+	assert.Equal(t, map[string]int{
+		"User alice failed login from 10.0.0.1": 2,
+		"User bob logged out":                   1,
+		"Disk full on /var":                     1,
+	}, c.Counts())
+}
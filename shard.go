@@ -0,0 +1,63 @@
+package snowberry
+
+import (
+	"sync"
+	"time"
+)
+
+// NewShardedCounter returns a Counter, see NewCounter, whose top-level branches are locked independently of each
+// other. Assignments of inputs whose first key already has a branch are processed in parallel with assignments to other
+// branches, while inputs which need the whole tree, such as inputs with a new first key, wait for the assignments in
+// progress and hold the whole tree for themselves. Every assignment sees the same tree as it would in a Counter, so
+// results are identical to those of a Counter which received the inputs in the same order.
+func NewShardedCounter(step int, scoreThreshold float32) *Counter {
+	c := NewCounter(step, scoreThreshold)
+	c.sharded = true
+
+	return c
+}
+
+// assignSharded assigns f, n times, within the top-level branch matching its first key, holding only the lock of that
+// branch. It returns false, without assigning, when the assignment could involve more than one branch.
+func (c *Counter) assignSharded(f *fruit, n int, at time.Time, debug *AssignDebug) (AssignResult, bool) {
+	// Fuzzy traversal compares against sibling branches, which belong to other shards, and eviction may remove any
+	// cluster
+	if !c.sharded || c.maxKeyEdits > 0 || c.maxClusters > 0 {
+		return AssignResult{}, false
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	// Inputs shorter than a key are compared against the whole tree
+	if f.length() < c.tree.end() {
+		return AssignResult{}, false
+	}
+
+	key := f.key(c.tree.start, c.tree.end())
+	shard, ok := c.tree.branches[key]
+	if !ok {
+		return AssignResult{}, false
+	}
+
+	lock, _ := c.shards.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	cl, score, created := c.place(shard, f, debug)
+	cl.add(f, n, at)
+
+	return cl.assignResult(f, score, created), true
+}
+
+// readLock locks the Counter against changes and returns the matching unlock. Readers share the lock, except in a
+// sharded Counter, whose shards change the tree while holding the lock shared.
+func (c *Counter) readLock() func() {
+	if c.sharded {
+		c.lock.Lock()
+		return c.lock.Unlock
+	}
+
+	c.lock.RLock()
+	return c.lock.RUnlock
+}
@@ -0,0 +1,46 @@
+package snowberry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedCounter(t *testing.T) {
+	// Every producer has its own prefixes, so the order between producers doesn't change the result
+	producers := [][]string{
+		{"Connection reset by peer %d", "Connection refused on port %d", "Connection timed out after %ds"},
+		{"Disk quota exceeded for user %d", "Disk /dev/sd%d is failing"},
+		{"User %d logged in", "User %d logged out", "User %d changed password"},
+		{"Mango number %d is ripe", "Mango number %d is rotten"},
+	}
+
+	sequential := NewCounter(3, 0.80)
+	sharded := NewShardedCounter(3, 0.80)
+
+	var wg sync.WaitGroup
+	for _, formats := range producers {
+		for i := 0; i < 200; i++ {
+			for _, format := range formats {
+				sequential.Assign(fmt.Sprintf(format, i))
+			}
+		}
+
+		wg.Add(1)
+		go func(formats []string) {
+			defer wg.Done()
+
+			for i := 0; i < 200; i++ {
+				for _, format := range formats {
+					sharded.Assign(fmt.Sprintf(format, i))
+				}
+			}
+		}(formats)
+	}
+	wg.Wait()
+
+	assert.Equal(t, sequential.Counts(), sharded.Counts())
+	assert.Equal(t, sequential.CountsByID(), sharded.CountsByID())
+}
@@ -0,0 +1,397 @@
+package snowberry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshotVersion is written to every snapshot and bumped whenever the format changes in a way older readers can't read.
+// Version 2 added masks and normalizers, which a version 1 reader would silently drop. Every version up to the current
+// one can be read.
+const snapshotVersion = 2
+
+// snapshotMagic begins every binary snapshot. JSON snapshots begin with '{' instead.
+var snapshotMagic = []byte("snowberry\x00")
+
+type counterSnapshot struct {
+	Version          int                  `json:"version"`
+	Step             int                  `json:"step"`
+	ScoreThreshold   float32              `json:"scoreThreshold"`
+	Scorer           string               `json:"scorer,omitempty"`
+	Sharded          bool                 `json:"sharded,omitempty"`
+	TokenKeys        bool                 `json:"tokenKeys,omitempty"`
+	MaxKeyEdits      int                  `json:"maxKeyEdits,omitempty"`
+	Exemplars        int                  `json:"exemplars,omitempty"`
+	HistoryBucket    time.Duration        `json:"historyBucket,omitempty"`
+	HistoryRetention int                  `json:"historyRetention,omitempty"`
+	RateHalfLife     time.Duration        `json:"rateHalfLife,omitempty"`
+	BaselineHalfLife time.Duration        `json:"baselineHalfLife,omitempty"`
+	MaxClusters      int                  `json:"maxClusters,omitempty"`
+	EvictionPolicy   EvictionPolicy       `json:"evictionPolicy,omitempty"`
+	Clock            uint64               `json:"clock,omitempty"`
+	EvictionAge      int                  `json:"evictionAge,omitempty"`
+	Created          uint64               `json:"created,omitempty"`
+	IgnorePatterns   []string             `json:"ignorePatterns,omitempty"`
+	Masks            []maskSnapshot       `json:"masks,omitempty"`
+	Normalizers      []normalizerSnapshot `json:"normalizers,omitempty"`
+	RejectPatterns   []string             `json:"rejectPatterns,omitempty"`
+	Clusters         []clusterSnapshot    `json:"clusters"`
+}
+
+type maskSnapshot struct {
+	Name        string `json:"name,omitempty"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type normalizerSnapshot struct {
+	Name  string   `json:"name"`
+	Words []string `json:"words,omitempty"`
+}
+
+type clusterSnapshot struct {
+	ID       ClusterID `json:"id"`
+	Original string    `json:"original"`
+	Masked   string    `json:"masked"`
+	Count    int       `json:"count"`
+	Template []string  `json:"template,omitempty"`
+	// Exemplars and VariantHashes sample the members, see variants
+	Exemplars     []string        `json:"exemplars,omitempty"`
+	VariantHashes []uint64        `json:"variantHashes,omitempty"`
+	FirstSeen     time.Time       `json:"firstSeen"`
+	LastSeen      time.Time       `json:"lastSeen"`
+	History       []historyBucket `json:"history,omitempty"`
+	// Rate and Baseline are in events per second, as of RatesAt
+	Rate     float64   `json:"rate,omitempty"`
+	Baseline float64   `json:"baseline,omitempty"`
+	RatesAt  time.Time `json:"ratesAt"`
+	// Created is the creation sequence number, see fruit
+	Created uint64 `json:"created,omitempty"`
+	// Used and Aging order the cluster for eviction, see cluster
+	Used  uint64 `json:"used,omitempty"`
+	Aging int    `json:"aging,omitempty"`
+}
+
+// Snapshot writes the configuration and every cluster of the Counter to w in a versioned binary format, which can be
+// read back with LoadCounter. The debug channel is not part of the snapshot, and neither is a Scorer other than the ones
+// provided by this package.
+func (c *Counter) Snapshot(w io.Writer) error {
+	if _, err := w.Write(snapshotMagic); err != nil {
+		return err
+	}
+
+	return gob.NewEncoder(w).Encode(c.snapshot())
+}
+
+// SnapshotJSON is Snapshot, in a versioned JSON format
+func (c *Counter) SnapshotJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c.snapshot())
+}
+
+func (c *Counter) snapshot() *counterSnapshot {
+	defer c.readLock()()
+
+	s := &counterSnapshot{
+		Version:          snapshotVersion,
+		Step:             c.tree.step,
+		ScoreThreshold:   c.scoreThreshold,
+		Scorer:           scorerName(c.scorer),
+		Sharded:          c.sharded,
+		TokenKeys:        c.tokenKeys,
+		MaxKeyEdits:      c.maxKeyEdits,
+		Exemplars:        c.exemplars,
+		HistoryBucket:    c.historyBucket,
+		HistoryRetention: c.historyRetention,
+		RateHalfLife:     c.rateHalfLife,
+		BaselineHalfLife: c.baselineHalfLife,
+		MaxClusters:      c.maxClusters,
+		EvictionPolicy:   c.evictionPolicy,
+		Clock:            c.clock,
+		EvictionAge:      c.evictionAge,
+		Created:          c.created,
+		IgnorePatterns:   patternStrings(maskPatterns(c.ignoreMasks)),
+		Masks:            maskSnapshots(c.masks),
+		Normalizers:      normalizerSnapshots(c.normalizers),
+		RejectPatterns:   patternStrings(c.rejectPatterns),
+		Clusters:         make([]clusterSnapshot, 0, len(c.clusters)),
+	}
+
+	for _, cl := range c.sortedClusters() {
+		cs := clusterSnapshot{
+			ID:        cl.fruit.id,
+			Original:  cl.fruit.original,
+			Masked:    cl.fruit.masked,
+			Count:     cl.count,
+			Template:  cl.template,
+			FirstSeen: cl.firstSeen,
+			LastSeen:  cl.lastSeen,
+			Created:   cl.fruit.created,
+			Used:      cl.used,
+			Aging:     cl.aging,
+		}
+
+		if cl.variants != nil {
+			cs.Exemplars = cl.variants.originals()
+			cs.VariantHashes = cl.variants.hashes
+		}
+		if cl.history != nil {
+			cs.History = cl.history.buckets
+		}
+		if cl.rates != nil {
+			cs.Rate, cs.Baseline, cs.RatesAt = cl.rates.rate, cl.rates.baseline, cl.rates.at
+		}
+
+		s.Clusters = append(s.Clusters, cs)
+	}
+
+	return s
+}
+
+// LoadCounter reads a Counter from a snapshot written by Snapshot or SnapshotJSON. Counters which used a custom Scorer
+// are loaded with the default one, and the custom Scorer must be set again with WithScorer. Likewise, custom Normalizers
+// are left out and must be added again with WithNormalizers, and an eviction callback must be set again with
+// WithMaxClusters. Snapshots with a step below 1, repeated cluster IDs, or masked strings which do not follow from
+// their originals are rejected with an error.
+func LoadCounter(r io.Reader) (*Counter, error) {
+	br := bufio.NewReader(r)
+
+	var s counterSnapshot
+	if header, err := br.Peek(len(snapshotMagic)); err == nil && bytes.Equal(header, snapshotMagic) {
+		_, _ = br.Discard(len(snapshotMagic))
+		if err := gob.NewDecoder(br).Decode(&s); err != nil {
+			return nil, fmt.Errorf("snowberry: decoding snapshot: %w", err)
+		}
+	} else if err := json.NewDecoder(br).Decode(&s); err != nil {
+		return nil, fmt.Errorf("snowberry: decoding snapshot: %w", err)
+	}
+
+	if s.Version < 1 || s.Version > snapshotVersion {
+		return nil, fmt.Errorf("snowberry: unsupported snapshot version %d", s.Version)
+	}
+
+	return s.restore()
+}
+
+func (s *counterSnapshot) restore() (*Counter, error) {
+	ignorePatterns, err := compilePatterns(s.IgnorePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	masks, err := compileMasks(s.Masks)
+	if err != nil {
+		return nil, err
+	}
+
+	rejectPatterns, err := compilePatterns(s.RejectPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Step < 1 {
+		return nil, fmt.Errorf("snowberry: invalid snapshot step %d", s.Step)
+	}
+
+	// Custom Normalizers are not restored, so masked strings can only be checked when every Normalizer is
+	normalizers := normalizersBySnapshot(s.Normalizers)
+	checkMasked := len(normalizers) == len(s.Normalizers)
+
+	c := NewCounter(s.Step, s.ScoreThreshold).
+		WithScorer(scorerByName(s.Scorer)).
+		WithFuzzyTraversal(s.MaxKeyEdits).
+		WithExemplars(s.Exemplars).
+		WithHistory(s.HistoryBucket, s.HistoryRetention).
+		WithRates(s.RateHalfLife, s.BaselineHalfLife).
+		WithMaxClusters(s.MaxClusters, s.EvictionPolicy, nil).
+		WithIgnoreAssign(ignorePatterns).
+		WithMasks(masks...).
+		WithNormalizers(normalizers...).
+		WithRejectAssign(rejectPatterns)
+	c.tokenKeys = s.TokenKeys
+	c.sharded = s.Sharded
+	c.clock, c.evictionAge = s.Clock, s.EvictionAge
+
+	for _, cs := range s.Clusters {
+		if _, ok := c.clusters[cs.ID]; ok {
+			return nil, fmt.Errorf("snowberry: duplicate snapshot cluster %q", cs.ID)
+		}
+
+		// The masks are applied again to learn which of them matched, and to check the masked string
+		f := newFruit(cs.Original).withMasks(c.masks).withMasks(c.ignoreMasks)
+		if checkMasked && f.withNormalizers(c.normalizers).masked != cs.Masked {
+			return nil, fmt.Errorf("snowberry: snapshot cluster %q has masked %q, which does not match its original %q",
+				cs.ID, cs.Masked, cs.Original)
+		}
+		f.masked = cs.Masked
+		f.segment(c.tokenKeys)
+
+		c.tree.findTerminatingBranch(f).addFruit(f)
+
+		cl := c.registerCluster(f, cs.ID)
+		cl.count = cs.Count
+		cl.template = cs.Template
+		cl.firstSeen, cl.lastSeen = cs.FirstSeen, cs.LastSeen
+		cl.used, cl.aging = cs.Used, cs.Aging
+		if cs.Created > 0 {
+			f.created = cs.Created
+		}
+		if cl.history != nil {
+			cl.history.buckets = cs.History
+		}
+		if cl.rates != nil {
+			cl.rates.rate, cl.rates.baseline, cl.rates.at = cs.Rate, cs.Baseline, cs.RatesAt
+		}
+
+		if cl.variants != nil {
+			for _, hash := range cs.VariantHashes {
+				cl.variants.addHash(hash)
+			}
+			for _, e := range cs.Exemplars {
+				cl.variants.addExemplar(variantHash(e), e)
+			}
+		}
+	}
+
+	c.created = max(c.created, s.Created)
+	// The clusters were ordered for eviction before their counts and uses were restored
+	c.resetEvictionOrder()
+
+	return c, nil
+}
+
+func patternStrings(patterns []*regexp.Regexp) []string {
+	var s []string
+	for _, p := range patterns {
+		s = append(s, p.String())
+	}
+
+	return s
+}
+
+func maskSnapshots(masks []Mask) []maskSnapshot {
+	var s []maskSnapshot
+	for _, m := range masks {
+		s = append(s, maskSnapshot{Name: m.Name, Pattern: m.Pattern.String(), Replacement: m.Replacement})
+	}
+
+	return s
+}
+
+func compileMasks(s []maskSnapshot) ([]Mask, error) {
+	var masks []Mask
+	for _, m := range s {
+		r, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("snowberry: compiling snapshot mask %q: %w", m.Name, err)
+		}
+
+		masks = append(masks, Mask{Name: m.Name, Pattern: r, Replacement: m.Replacement})
+	}
+
+	return masks, nil
+}
+
+func compilePatterns(s []string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, p := range s {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("snowberry: compiling snapshot pattern: %w", err)
+		}
+
+		patterns = append(patterns, r)
+	}
+
+	return patterns, nil
+}
+
+// normalizerSnapshots describes the Normalizers provided by this package, and other Normalizers by a placeholder which
+// normalizersBySnapshot skips
+func normalizerSnapshots(normalizers []Normalizer) []normalizerSnapshot {
+	var s []normalizerSnapshot
+	for _, n := range normalizers {
+		switch n := n.(type) {
+		case NFKCNormalizer:
+			s = append(s, normalizerSnapshot{Name: "nfkc"})
+		case CaseFoldNormalizer:
+			s = append(s, normalizerSnapshot{Name: "case-fold"})
+		case WhitespaceNormalizer:
+			s = append(s, normalizerSnapshot{Name: "whitespace"})
+		case PunctuationNormalizer:
+			s = append(s, normalizerSnapshot{Name: "punctuation"})
+		case StopWordNormalizer:
+			words := n.Words()
+			sort.Strings(words)
+			s = append(s, normalizerSnapshot{Name: "stop-words", Words: words})
+		default:
+			s = append(s, normalizerSnapshot{Name: "custom"})
+		}
+	}
+
+	return s
+}
+
+// normalizersBySnapshot is the inverse of normalizerSnapshots, skipping unknown names
+func normalizersBySnapshot(s []normalizerSnapshot) []Normalizer {
+	var normalizers []Normalizer
+	for _, n := range s {
+		switch n.Name {
+		case "nfkc":
+			normalizers = append(normalizers, NFKCNormalizer{})
+		case "case-fold":
+			normalizers = append(normalizers, CaseFoldNormalizer{})
+		case "whitespace":
+			normalizers = append(normalizers, WhitespaceNormalizer{})
+		case "punctuation":
+			normalizers = append(normalizers, PunctuationNormalizer{})
+		case "stop-words":
+			normalizers = append(normalizers, NewStopWordNormalizer(n.Words...))
+		}
+	}
+
+	return normalizers
+}
+
+// scorerName returns the name a Scorer provided by this package is stored under in a snapshot, or "" for other Scorers
+func scorerName(s Scorer) string {
+	switch s := s.(type) {
+	case LevenshteinScorer:
+		return "levenshtein"
+	case DamerauLevenshteinScorer:
+		return "damerau-levenshtein"
+	case JaroWinklerScorer:
+		return "jaro-winkler"
+	case TokenSetRatioScorer:
+		return "token-set-ratio"
+	case NGramCosineScorer:
+		return "n-gram-cosine:" + strconv.Itoa(s.N)
+	}
+
+	return ""
+}
+
+// scorerByName is the inverse of scorerName, falling back to the default Scorer for unknown names
+func scorerByName(name string) Scorer {
+	switch {
+	case name == "damerau-levenshtein":
+		return DamerauLevenshteinScorer{}
+	case name == "jaro-winkler":
+		return JaroWinklerScorer{}
+	case name == "token-set-ratio":
+		return TokenSetRatioScorer{}
+	case strings.HasPrefix(name, "n-gram-cosine:"):
+		n, _ := strconv.Atoi(strings.TrimPrefix(name, "n-gram-cosine:"))
+		return NGramCosineScorer{N: n}
+	}
+
+	return LevenshteinScorer{}
+}
@@ -0,0 +1,134 @@
+package snowberry
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	f := []string{
+		"An aardvark ate my apple.",
+		"An apple is a fruit.",
+		"A mango is a fruit.",
+		"There's a snake in my boot.",
+		"There's a snail in my boot.",
+		"To infinity and beyond!",
+		"2024-09-08T23:30:03.333",
+	}
+
+	newCounter := func() *Counter {
+		return NewCounter(2, 0.70).
+			WithScorer(NGramCosineScorer{N: 2}).
+			WithTokenKeys().
+			WithFuzzyTraversal(1).
+			WithExemplars(2).
+			WithHistory(time.Minute, 10).
+			WithRates(time.Minute, time.Hour).
+			WithMaxClusters(3, EvictLeastRecentlyUsed, nil).
+			WithIgnoreAssign([]*regexp.Regexp{regexp.MustCompile("[.!]$"), regexp.MustCompile("[,']")}).
+			WithMasks(Mask{Name: "snake", Pattern: regexp.MustCompile("sna[a-z]+"), Replacement: "<SNAKE>"}).
+			WithNormalizers(CaseFoldNormalizer{}, NewStopWordNormalizer("a", "my"), WhitespaceNormalizer{}).
+			WithRejectAssign([]*regexp.Regexp{regexp.MustCompile("\\d{4}")})
+	}
+
+	for name, snapshot := range map[string]func(c *Counter, w io.Writer) error{
+		"binary": (*Counter).Snapshot,
+		"json":   (*Counter).SnapshotJSON,
+	} {
+		c := newCounter()
+		at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+		for i, s := range f[:4] {
+			c.AssignAt(s, at.Add(time.Duration(i)*time.Minute))
+		}
+
+		var buf bytes.Buffer
+		assert.NoError(t, snapshot(c, &buf), name)
+
+		loaded, err := LoadCounter(&buf)
+		assert.NoError(t, err, name)
+
+		assert.Equal(t, c.Clusters(), loaded.Clusters(), name)
+		assert.Equal(t, c.tree, loaded.tree, name)
+		assert.Equal(t, c.scorer, loaded.scorer, name)
+		assert.Equal(t, c.HotClusters(at, 10), loaded.HotClusters(at, 10), name)
+
+		// A restored counter carries on exactly where the original left off
+		for i, s := range f[4:] {
+			at := at.Add(time.Duration(i) * time.Hour)
+			assert.Equal(t, c.AssignAt(s, at), loaded.AssignAt(s, at), name)
+		}
+		assert.Equal(t, c.Clusters(), loaded.Clusters(), name)
+	}
+}
+
+func TestLoadCounterErrors(t *testing.T) {
+	_, err := LoadCounter(strings.NewReader(`{"version": 3, "step": 2}`))
+	assert.ErrorContains(t, err, "unsupported snapshot version 3")
+
+	_, err = LoadCounter(strings.NewReader(`{"version": 1, "step": 2, "ignorePatterns": ["("]}`))
+	assert.Error(t, err)
+
+	_, err = LoadCounter(strings.NewReader("snowberry\x00garbage"))
+	assert.Error(t, err)
+
+	_, err = LoadCounter(strings.NewReader(`{"version": 2, "step": 0}`))
+	assert.ErrorContains(t, err, "invalid snapshot step 0")
+
+	_, err = LoadCounter(strings.NewReader(`{"version": 2, "step": 2, "clusters": [` +
+		`{"id": "a", "original": "abc", "masked": "abc", "count": 1},` +
+		`{"id": "a", "original": "xyz", "masked": "xyz", "count": 1}]}`))
+	assert.ErrorContains(t, err, `duplicate snapshot cluster "a"`)
+
+	_, err = LoadCounter(strings.NewReader(`{"version": 2, "step": 2, "normalizers": [{"name": "case-fold"}],` +
+		`"clusters": [{"id": "a", "original": "ABC", "masked": "ABC", "count": 1}]}`))
+	assert.ErrorContains(t, err, `snapshot cluster "a" has masked "ABC", which does not match its original "ABC"`)
+
+	// Custom Normalizers are not restored, so their masked strings are taken as they are
+	c := NewCounter(2, 0.70).WithNormalizers(NormalizerFunc(strings.ToUpper))
+	c.Assign("abc")
+	var buf bytes.Buffer
+	assert.NoError(t, c.SnapshotJSON(&buf))
+	loaded, err := LoadCounter(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, c.Counts(), loaded.Counts())
+}
+
+func TestSnapshotAfterRemoval(t *testing.T) {
+	for name, newCounter := range map[string]func() *Counter{
+		"unassign": func() *Counter {
+			c := NewCounter(2, 0.70)
+			c.Assign("abcdefgh")
+			c.Assign("abcdxyzw")
+			c.Unassign("abcdxyzw")
+
+			return c
+		},
+		"eviction": func() *Counter {
+			c := NewCounter(2, 0.70).WithMaxClusters(2, EvictLeastRecentlyUsed, nil)
+			c.Assign("abcdxyzw")
+			c.Assign("abcdefgh")
+			c.Assign("zzzzzzzz")
+
+			return c
+		},
+	} {
+		c := newCounter()
+
+		var buf bytes.Buffer
+		assert.NoError(t, c.Snapshot(&buf), name)
+
+		loaded, err := LoadCounter(&buf)
+		assert.NoError(t, err, name)
+
+		// The remaining fruit sits where a fresh insert puts it, so both carry on alike
+		assert.Equal(t, c.tree, loaded.tree, name)
+		assert.Equal(t, c.Assign("abcdefgQ"), loaded.Assign("abcdefgQ"), name)
+		assert.Equal(t, withoutTimes(c.Clusters()), withoutTimes(loaded.Clusters()), name)
+	}
+}
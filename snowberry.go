@@ -1,23 +1,37 @@
 package snowberry
 
 import (
+	"math"
 	"regexp"
+	"strings"
 	"sync"
-
-	levenshtein "github.com/ka-weihe/fast-levenshtein"
+	"time"
 )
 
 type fruit struct {
+	id ClusterID
+	// created numbers the fruit in order of creation, and breaks ties between equally scored candidates
+	created          uint64
 	original, masked string
+	// bounds holds the byte offset of every segment in masked, followed by len(masked)
+	bounds []int
+	// tokenized is set when segments are whole tokens rather than grapheme clusters
+	tokenized bool
+	// masks names the masks which matched the original, in the order they were applied
+	masks []string
 }
 
 func newFruit(s string) *fruit {
 	return &fruit{original: s, masked: s}
 }
 
-func (f *fruit) withIgnorePatterns(patterns []*regexp.Regexp) *fruit {
-	for _, p := range patterns {
-		f.masked = p.ReplaceAllString(f.masked, "")
+// segment splits the masked string into the segments used as tree keys, either grapheme clusters or tokens
+func (f *fruit) segment(tokens bool) *fruit {
+	f.tokenized = tokens
+	if tokens {
+		f.bounds = tokenBounds(f.masked)
+	} else {
+		f.bounds = graphemeBounds(f.masked)
 	}
 
 	return f
@@ -33,23 +47,41 @@ func (f *fruit) shouldReject(patterns []*regexp.Regexp) bool {
 	return false
 }
 
+// length returns the number of segments in the masked string
+func (f *fruit) length() int {
+	return len(f.bounds) - 1
+}
+
+// key returns the masked string from segment start up to, but not including, segment end. Whitespace surrounding
+// tokens is not part of the key.
 func (f *fruit) key(start, end int) string {
-	return f.masked[start:end]
+	k := f.masked[f.bounds[start]:f.bounds[end]]
+	if f.tokenized {
+		return strings.TrimSpace(k)
+	}
+
+	return k
 }
 
-// compare returns matching index E [0..1] from two strings and an edit distance. 1 represents a perfect match.
-func (f *fruit) compare(start int, other *fruit) float32 {
-	t := f.masked[start:]
-	o := other.masked[start:]
+// tail returns the masked string from segment start onwards
+func (f *fruit) tail(start int) string {
+	return f.masked[f.bounds[start]:]
+}
 
-	// Calculate string distance
-	distance := levenshtein.Distance(t, o)
+// compare returns matching index E [0..1] from two strings, as calculated by the scorer. 1 represents a perfect match.
+func (f *fruit) compare(start int, other *fruit, scorer Scorer) float32 {
+	return scorer.Score(f.tail(start), other.tail(start))
+}
 
-	// Compare rune arrays length and make a matching percentage between them
-	if len(t) >= len(o) {
-		return float32(len(t)-distance) / float32(len(t))
+// compareAbove is compare, which may stop early and return false when the score is certain not to be above floor
+func (f *fruit) compareAbove(start int, other *fruit, scorer Scorer, floor float32) (float32, bool) {
+	if bounded, ok := scorer.(BoundedScorer); ok {
+		return bounded.ScoreAbove(f.tail(start), other.tail(start), floor)
 	}
-	return float32(len(o)-distance) / float32(len(o))
+
+	score := f.compare(start, other, scorer)
+
+	return score, score > floor
 }
 
 type branch struct {
@@ -64,7 +96,7 @@ func (b *branch) end() int {
 
 // findTerminatingBranch finds the deepest branch matching the provided masked string
 func (b *branch) findTerminatingBranch(f *fruit) *branch {
-	if len(f.masked) < b.end() {
+	if f.length() < b.end() {
 		return b
 	}
 
@@ -75,6 +107,55 @@ func (b *branch) findTerminatingBranch(f *fruit) *branch {
 	return b
 }
 
+// nearBranch is a branch whose descendant fruit are compared from start, rather than from the branch's own start
+type nearBranch struct {
+	*branch
+	start int
+}
+
+// findNearBranches follows the same path as findTerminatingBranch, but also collects every sibling passed along the way
+// whose key is within maxKeyEdits of the provided masked string's key. The terminating branch is always the first
+// result. Sibling fruit are compared from the start of the level where the keys differ, so the typo counts against them.
+func (b *branch) findNearBranches(f *fruit, maxKeyEdits int) []nearBranch {
+	var near []nearBranch
+	for {
+		if f.length() < b.end() {
+			break
+		}
+
+		key := f.key(b.start, b.end())
+		if maxKeyEdits > 0 {
+			k := []rune(key)
+			for siblingKey, sibling := range b.branches {
+				s := []rune(siblingKey)
+				if siblingKey == key || abs(len(s)-len(k)) > maxKeyEdits {
+					continue
+				}
+
+				if levenshteinDistance(k, s) <= maxKeyEdits {
+					near = append(near, nearBranch{branch: sibling, start: b.start})
+				}
+			}
+		}
+
+		child, ok := b.branches[key]
+		if !ok {
+			break
+		}
+		b = child
+	}
+
+	return append([]nearBranch{{branch: b, start: b.start}}, near...)
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+
+	return i
+}
+
 // allDescendantFruit returns all fruit on the current branch and all child branches
 func (b *branch) allDescendantFruit() []*fruit {
 	f := make([]*fruit, len(b.fruit))
@@ -93,7 +174,7 @@ func (b *branch) addFruit(f *fruit) {
 
 	var stuntedFruit []*fruit
 	for _, fr := range b.fruit {
-		if len(fr.masked) < b.end() {
+		if fr.length() < b.end() {
 			stuntedFruit = append(stuntedFruit, fr)
 			continue
 		}
@@ -116,33 +197,121 @@ func (b *branch) addFruit(f *fruit) {
 
 // Counter accepts strings and groups similar strings together, based on input parameters
 type Counter struct {
-	lock   sync.Mutex
-	tree   *branch
-	counts map[string]int
+	// lock is held exclusively by every operation, except assignments to existing shards of a sharded Counter, which
+	// hold it shared alongside the lock of their shard.
+	lock     sync.RWMutex
+	tree     *branch
+	sharded  bool
+	shards   sync.Map // key of a top-level branch -> *sync.Mutex
+	clusters map[ClusterID]*cluster
+	// clustersLock guards clusters and created against concurrent shards
+	clustersLock sync.Mutex
+	// created numbers clusters in order of creation
+	created uint64
 
 	scoreThreshold                 float32
-	ignorePatterns, rejectPatterns []*regexp.Regexp
+	scorer                         Scorer
+	tokenKeys                      bool
+	maxKeyEdits                    int
+	exemplars                      int
+	historyBucket                  time.Duration
+	historyRetention               int
+	rateHalfLife, baselineHalfLife time.Duration
+	maxClusters                    int
+	evictionPolicy                 EvictionPolicy
+	onEvict                        func(Cluster)
+	masks, ignoreMasks             []Mask
+	normalizers                    []Normalizer
+	rejectPatterns                 []*regexp.Regexp
 	debugChannel                   chan *AssignDebug
+
+	// evictionOrder orders clusters for eviction, nil without a maximum. clock counts assignments, and evictionAge is
+	// the aged count of the last evicted cluster, see EvictLeastFrequentlyUsed.
+	evictionOrder evictionOrder
+	clock         uint64
+	evictionAge   int
 }
 
-// NewCounter a new Counter. `step` represents the size of substrings used when building the tree-like index.
+// NewCounter a new Counter. `step` represents the size of substrings, in grapheme clusters, used when building the
+// tree-like index.
 // `scoreThreshold` is a value between 0.0 and 1.0, where 1.0 represents a perfect match. A match must have a score
 // above the threshold to be matched. The match with the highest score in the candidate set is always chosen.
 func NewCounter(step int, scoreThreshold float32) *Counter {
 	return &Counter{
-		lock: sync.Mutex{},
+		lock: sync.RWMutex{},
 		tree: &branch{
 			step:     step,
 			branches: make(map[string]*branch),
 		},
-		counts:         make(map[string]int),
+		clusters:       make(map[ClusterID]*cluster),
 		scoreThreshold: scoreThreshold,
+		scorer:         LevenshteinScorer{},
 	}
 }
 
-// WithIgnoreAssign returns a Counter which will ignore the targeted contents of assignments matching all regex
+// WithScorer returns a Counter which will score candidates with the provided Scorer instead of LevenshteinScorer
+func (c *Counter) WithScorer(s Scorer) *Counter {
+	c.scorer = s
+
+	return c
+}
+
+// WithTokenKeys returns a Counter which builds the tree-like index from whole tokens instead of grapheme clusters, so
+// `step` counts tokens. A token is a run of letters and numbers or a single punctuation character or symbol, and
+// whitespace only separates tokens. Strings sharing leading words share a subtree, regardless of the length of the words.
+// Must be set before the first assignment.
+func (c *Counter) WithTokenKeys() *Counter {
+	c.tokenKeys = true
+
+	return c
+}
+
+// WithFuzzyTraversal returns a Counter which, while descending the tree-like index, also compares against the fruit of
+// sibling branches whose keys are within `maxKeyEdits` edits of the input's key. This recovers matches for inputs with a
+// typo early in the string, at the cost of more comparisons per assignment. 0, the default, follows exact keys only.
+func (c *Counter) WithFuzzyTraversal(maxKeyEdits int) *Counter {
+	c.maxKeyEdits = maxKeyEdits
+
+	return c
+}
+
+// WithExemplars returns a Counter which keeps a sample of up to `size` distinct originals of the members of every
+// category, along with the number of distinct originals, see Cluster. Must be set before the first assignment.
+func (c *Counter) WithExemplars(size int) *Counter {
+	c.exemplars = size
+
+	return c
+}
+
+// WithHistory returns a Counter which keeps a histogram of the assignments of every category over time, in buckets of
+// the provided width. Each category keeps the buckets of the `retention` most recent widths, counting back from its
+// newest bucket, or every bucket when `retention` is 0. Must be set before the first assignment.
+func (c *Counter) WithHistory(bucket time.Duration, retention int) *Counter {
+	c.historyBucket = bucket
+	c.historyRetention = retention
+
+	return c
+}
+
+// WithRates returns a Counter which tracks exponentially decayed rates of every category, updated on every assignment:
+// a current rate over `halfLife`, and a baseline over the longer `baselineHalfLife`. See HotClusters and RisingClusters.
+// A `halfLife` of 0 or less tracks no rates, and a `baselineHalfLife` of 0 or less tracks no baseline, leaving the
+// baseline at 0. Must be set before the first assignment.
+func (c *Counter) WithRates(halfLife, baselineHalfLife time.Duration) *Counter {
+	c.rateHalfLife = halfLife
+	c.baselineHalfLife = baselineHalfLife
+
+	return c
+}
+
+// WithIgnoreAssign returns a Counter which will ignore the targeted contents of assignments matching all regex,
+// replacing the regex of any earlier call. The regex apply as masks with an empty replacement, after the masks of
+// WithMasks.
 func (c *Counter) WithIgnoreAssign(r []*regexp.Regexp) *Counter {
-	c.ignorePatterns = r
+	c.ignoreMasks = nil
+	for _, p := range r {
+		c.ignoreMasks = append(c.ignoreMasks, Mask{Pattern: p})
+	}
 
 	return c
 }
@@ -163,15 +332,55 @@ func (c *Counter) WithDebugChannel(debugChannel chan *AssignDebug) *Counter {
 
 // AssignDebug contains details about every match processed
 type AssignDebug struct {
-	Input, MaskedInput         string
+	Input, MaskedInput string
+	// Masks names the masks which matched the input, in the order they were applied
+	Masks                      []string
 	Rejected                   bool
 	BestMatch, BestMatchMasked string
 	BestMatchScore             float32
 	BestMatchAccepted          bool
 }
 
-// Assign assigns input to a category.
-func (c *Counter) Assign(input string) {
+// AssignResult describes the category an input was assigned to
+type AssignResult struct {
+	// ID identifies the category
+	ID ClusterID
+	// Representative is the original input which created the category
+	Representative string
+	// Score is the similarity of the input to the representative, 1 for a newly created category
+	Score float32
+	// Created is set when no existing category matched and the input created a new one
+	Created bool
+	// Rejected is set when the input matched a reject pattern. All other fields are left empty.
+	Rejected bool
+	// Masks names the masks which matched the input, in the order they were applied
+	Masks []string
+}
+
+// Assign assigns input to a category and returns the category it was assigned to.
+func (c *Counter) Assign(input string) AssignResult {
+	return c.AssignAt(input, time.Now())
+}
+
+// AssignAt is Assign for an input which occurred at the provided time, rather than now. The time is recorded in the
+// first seen, last seen and history of the category.
+func (c *Counter) AssignAt(input string, at time.Time) AssignResult {
+	return c.AssignNAt(input, 1, at)
+}
+
+// AssignN assigns input, which occurred n times, to a category in a single step, as for pre-aggregated inputs. The
+// category counts n members, while its template and exemplars count input once.
+func (c *Counter) AssignN(input string, n int) AssignResult {
+	return c.AssignNAt(input, n, time.Now())
+}
+
+// AssignNAt is AssignN for an input which occurred n times at the provided time, see AssignAt. It assigns nothing and
+// returns an empty result when n is less than 1.
+func (c *Counter) AssignNAt(input string, n int, at time.Time) AssignResult {
+	if n < 1 {
+		return AssignResult{}
+	}
+
 	debug := &AssignDebug{Input: input}
 	defer func() {
 		if c.debugChannel != nil {
@@ -179,58 +388,124 @@ func (c *Counter) Assign(input string) {
 		}
 	}()
 
-	n := newFruit(input).withIgnorePatterns(c.ignorePatterns)
+	f, rejected := c.prepare(input, debug)
+	if rejected {
+		return AssignResult{Rejected: true}
+	}
+
+	if result, ok := c.assignSharded(f, n, at, debug); ok {
+		return result
+	}
+
+	// Code after this point needs a lock to be thread safe
+	c.lock.Lock()
+
+	cl, score, created := c.place(c.tree, f, debug)
+	cl.add(f, n, at)
+	c.touch(cl)
+	evicted := c.evict(cl)
+
+	c.lock.Unlock()
+	c.reportEvicted(evicted)
+
+	return cl.assignResult(f, score, created)
+}
+
+// prepare masks, normalizes and segments input, and reports whether it should be rejected
+func (c *Counter) prepare(input string, debug *AssignDebug) (*fruit, bool) {
+	n := newFruit(input).withMasks(c.masks).withMasks(c.ignoreMasks).withNormalizers(c.normalizers).segment(c.tokenKeys)
 	debug.MaskedInput = n.masked
+	debug.Masks = n.masks
 
 	if n.shouldReject(c.rejectPatterns) {
 		debug.Rejected = true
 
-		return
+		return n, true
 	}
 
-	// Code after this point needs a lock to be thread safe
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	return n, false
+}
 
-	// Match the first part of the masked string until there's a mismatch
-	b := c.tree.findTerminatingBranch(n)
-
-	var bestMatch *fruit
-	var bestScore float32 = 0
-	for _, f := range b.allDescendantFruit() {
-		if score := n.compare(b.start, f); score > bestScore {
-			bestScore = score
-			bestMatch = f
-
-			// Strings are perfectly equal and there is no point in continuing the search.
-			if score == 1 {
-				break
-			}
-		}
-	}
+// place finds the cluster n belongs to in the tree below root, creating one when there is no match above the threshold.
+// The returned score is that of the match, 1 when created. The debug details of the search are recorded when debug is
+// not nil.
+func (c *Counter) place(root *branch, n *fruit, debug *AssignDebug) (*cluster, float32, bool) {
+	b, bestMatch, bestScore := c.search(root, n)
+
+	return c.settle(b, n, bestMatch, bestScore, debug)
+}
 
-	if bestMatch != nil {
+// settle joins n to bestMatch when its score is above the threshold, otherwise it adds n to b as a new cluster
+func (c *Counter) settle(b *branch, n, bestMatch *fruit, bestScore float32, debug *AssignDebug) (*cluster, float32, bool) {
+	if bestMatch != nil && debug != nil {
 		debug.BestMatch = bestMatch.original
 		debug.BestMatchMasked = bestMatch.masked
 		debug.BestMatchScore = bestScore
 	}
 
 	if bestScore > c.scoreThreshold && bestMatch != nil {
-		c.counts[bestMatch.masked]++
-		debug.BestMatchAccepted = true
+		if debug != nil {
+			debug.BestMatchAccepted = true
+		}
 
-		return
+		return c.cluster(bestMatch.id), bestScore, false
 	}
 
+	cl := c.newCluster(n)
 	b.addFruit(n)
-	c.counts[n.masked]++
+
+	return cl, 1, true
+}
+
+// search returns the terminating branch below root for the provided fruit, along with the best matching fruit and its
+// score
+func (c *Counter) search(root *branch, n *fruit) (*branch, *fruit, float32) {
+	// Match the first part of the masked string until there's a mismatch
+	near := root.findNearBranches(n, c.maxKeyEdits)
+	bestMatch, bestScore := c.bestMatch(n, near, nil, 0, 0)
+
+	return near[0].branch, bestMatch, bestScore
+}
+
+// bestMatch returns the best matching fruit for n within the near branches, considering only fruit created after
+// `since`, which still has to beat the provided best match.
+func (c *Counter) bestMatch(n *fruit, near []nearBranch, bestMatch *fruit, bestScore float32, since uint64) (*fruit, float32) {
+	// Candidates which cannot score above the threshold are of no use, unless the best match is wanted for debugging.
+	var floor float32 = 0
+	if c.debugChannel == nil {
+		floor = c.scoreThreshold
+	}
+
+	for _, b := range near {
+		for _, f := range b.allDescendantFruit() {
+			if f.created <= since {
+				continue
+			}
+
+			// Ties go to the oldest fruit, so that the result does not depend on the order of the search
+			score, ok := n.compareAbove(b.start, f, c.scorer, max(floor, math.Nextafter32(bestScore, 0)))
+			if ok && (score > bestScore || bestMatch == nil || f.created < bestMatch.created) {
+				bestScore = score
+				bestMatch = f
+
+				// Strings are perfectly equal and there is no point in continuing the search.
+				if score == 1 {
+					return bestMatch, bestScore
+				}
+			}
+		}
+	}
+
+	return bestMatch, bestScore
 }
 
 // Counts returns the original, unmasked map of categories and counts
 func (c *Counter) Counts() map[string]int {
+	defer c.readLock()()
+
 	ogCounts := make(map[string]int)
-	for _, f := range c.tree.allDescendantFruit() {
-		ogCounts[f.original] = c.counts[f.masked]
+	for _, cl := range c.clusters {
+		ogCounts[cl.fruit.original] = cl.count
 	}
 
 	return ogCounts
@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
+	"time"
 )
 
 func TestTree(t *testing.T) {
@@ -30,13 +31,13 @@ func TestTree(t *testing.T) {
 								start:    6,
 								step:     2,
 								branches: map[string]*branch{},
-								fruit:    []*fruit{{original: f[0], masked: f[0]}},
+								fruit:    []*fruit{newFruit(f[0]).segment(false)},
 							},
 							"pp": {
 								start:    6,
 								step:     2,
 								branches: map[string]*branch{},
-								fruit:    []*fruit{{original: f[1], masked: f[1]}},
+								fruit:    []*fruit{newFruit(f[1]).segment(false)},
 							},
 						},
 						fruit: nil,
@@ -48,13 +49,13 @@ func TestTree(t *testing.T) {
 				start:    2,
 				step:     2,
 				branches: map[string]*branch{},
-				fruit:    []*fruit{{original: f[2], masked: f[2]}},
+				fruit:    []*fruit{newFruit(f[2]).segment(false)},
 			},
 			"A ": {
 				start:    2,
 				step:     2,
 				branches: map[string]*branch{},
-				fruit:    []*fruit{{original: f[3], masked: f[3]}},
+				fruit:    []*fruit{newFruit(f[3]).segment(false)},
 			},
 		},
 		fruit: nil,
@@ -67,9 +68,9 @@ func TestTree(t *testing.T) {
 	}
 
 	for _, word := range f {
-		b := root.findTerminatingBranch(newFruit(word))
+		b := root.findTerminatingBranch(newFruit(word).segment(false))
 
-		b.addFruit(&fruit{original: word, masked: word})
+		b.addFruit(newFruit(word).segment(false))
 	}
 
 	assert.Equal(t, expectedTree, root)
@@ -116,3 +117,96 @@ func TestCounter(t *testing.T) {
 		"You've got a friend in me.":                             1,
 	}, c.Counts())
 }
+
+func TestCounterWithFuzzyTraversal(t *testing.T) {
+	f := []string{
+		"Error: disk quota exceeded for user 1",
+		"Errno 5: device not ready",
+		"Errno: disk quota exceeded for user 2",
+	}
+
+	c := NewCounter(5, 0.80)
+	for _, word := range f {
+		c.Assign(word)
+	}
+
+	assert.Equal(t, map[string]int{
+		"Error: disk quota exceeded for user 1": 1,
+		"Errno 5: device not ready":             1,
+		"Errno: disk quota exceeded for user 2": 1,
+	}, c.Counts())
+
+	c = NewCounter(5, 0.80).WithFuzzyTraversal(2)
+	for _, word := range f {
+		c.Assign(word)
+	}
+
+	assert.Equal(t, map[string]int{
+		"Error: disk quota exceeded for user 1": 2,
+		"Errno 5: device not ready":             1,
+	}, c.Counts())
+}
+
+func TestAssignResult(t *testing.T) {
+	c := NewCounter(2, 0.70).WithRejectAssign([]*regexp.Regexp{regexp.MustCompile("\\d{4}")})
+
+	created := c.Assign("There's a snake in my boot.")
+	assert.True(t, created.Created)
+	assert.False(t, created.Rejected)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, "There's a snake in my boot.", created.Representative)
+	assert.Equal(t, float32(1), created.Score)
+
+	matched := c.Assign("There's a snail in my boot.")
+	assert.False(t, matched.Created)
+	assert.Equal(t, created.ID, matched.ID)
+	assert.Equal(t, "There's a snake in my boot.", matched.Representative)
+	assert.Greater(t, matched.Score, float32(0.70))
+	assert.Less(t, matched.Score, float32(1))
+
+	other := c.Assign("To infinity and beyond!")
+	assert.True(t, other.Created)
+	assert.NotEqual(t, created.ID, other.ID)
+
+	assert.Equal(t, AssignResult{Rejected: true}, c.Assign("2024-09-08T23:30:03.333"))
+
+	// IDs do not depend on the Counter which created them
+	assert.Equal(t, created.ID, NewCounter(2, 0.70).Assign("There's a snake in my boot.").ID)
+}
+
+func TestAssignN(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+	newCounter := func() *Counter {
+		return NewCounter(2, 0.70).WithExemplars(5).WithHistory(time.Minute, 0).WithRates(time.Minute, time.Hour)
+	}
+
+	weighted, repeated := newCounter(), newCounter()
+	for i, s := range []string{"There's a snake in my boot.", "There's a snail in my boot.", "To infinity and beyond!"} {
+		weighted.AssignNAt(s, 1532+i, at)
+		for j := 0; j < 1532+i; j++ {
+			repeated.AssignAt(s, at)
+		}
+	}
+
+	assert.Equal(t, repeated.Clusters(), weighted.Clusters())
+	for i, r := range weighted.HotClusters(at, 10) {
+		assert.InDelta(t, repeated.HotClusters(at, 10)[i].Rate, r.Rate, 1e-9)
+	}
+	assert.Equal(t, map[string]int{
+		"There's a snake in my boot.": 3065,
+		"To infinity and beyond!":     1534,
+	}, weighted.Counts())
+
+	merged := newCounter()
+	merged.Merge(weighted)
+	assert.Equal(t, weighted.Clusters(), merged.Clusters())
+
+	assert.Equal(t, AssignResult{}, weighted.AssignN("To infinity and beyond!", 0))
+	assert.Equal(t, 1534, weighted.Counts()["To infinity and beyond!"])
+
+	// Sharded assignments are weighted alike
+	sharded := NewShardedCounter(2, 0.70)
+	sharded.AssignN("To infinity and beyond!", 2)
+	sharded.AssignN("To infinity and beyond!", 3)
+	assert.Equal(t, map[string]int{"To infinity and beyond!": 5}, sharded.Counts())
+}
@@ -0,0 +1,58 @@
+package snowberry
+
+import "strings"
+
+// templateWildcard stands in for the parts of a template which vary between the members of a cluster
+const templateWildcard = "<*>"
+
+// templateTokens splits s into the whitespace separated tokens templates are made of
+func templateTokens(s string) []string {
+	return strings.Fields(s)
+}
+
+// mergeTemplate returns the template covering both template and tokens. Tokens the two have in common, in the same
+// order, are kept and every run of tokens which differs is replaced by a single wildcard.
+func mergeTemplate(template, tokens []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of template[i:] and tokens[j:]
+	lcs := make([][]int, len(template)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(tokens)+1)
+	}
+	for i := len(template) - 1; i >= 0; i-- {
+		for j := len(tokens) - 1; j >= 0; j-- {
+			if template[i] == tokens[j] && template[i] != templateWildcard {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	merged := make([]string, 0, len(template))
+	wildcard := func() {
+		if len(merged) == 0 || merged[len(merged)-1] != templateWildcard {
+			merged = append(merged, templateWildcard)
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(template) && j < len(tokens) {
+		switch {
+		case template[i] == tokens[j] && template[i] != templateWildcard:
+			merged = append(merged, template[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			wildcard()
+			i++
+		default:
+			wildcard()
+			j++
+		}
+	}
+	if i < len(template) || j < len(tokens) {
+		wildcard()
+	}
+
+	return merged
+}
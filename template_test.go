@@ -0,0 +1,46 @@
+package snowberry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		template, tokens, expected string
+	}{
+		{"a b c", "a b c", "a b c"},
+		{"a b c", "a x c", "a <*> c"},
+		{"a b c", "a x y c", "a <*> c"},
+		{"a b c", "a c", "a <*> c"},
+		{"a b c", "x b c", "<*> b c"},
+		{"a b c", "a b", "a b <*>"},
+		{"a <*> c", "a x y c", "a <*> c"},
+		{"a <*> c", "a <*> c", "a <*> c"},
+		{"a b c", "x y z", "<*>"},
+		{"", "a", "<*>"},
+	} {
+		actual := mergeTemplate(templateTokens(tc.template), templateTokens(tc.tokens))
+		assert.Equal(t, templateTokens(tc.expected), actual, tc)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	c := NewCounter(5, 0.60)
+
+	for _, s := range []string{
+		"User alice failed login from 10.0.0.1",
+		"User bob failed login from 192.168.1.20",
+		"User carol failed login from 10.0.0.7",
+		"Disk full on /var",
+	} {
+		c.Assign(s)
+	}
+
+	assert.Equal(t, map[string]int{
+		"User <*> failed login from <*>": 3,
+		"Disk full on /var":              1,
+	}, c.Templates())
+	assert.Equal(t, "User <*> failed login from <*>", c.Clusters()[0].Template)
+}
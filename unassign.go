@@ -0,0 +1,137 @@
+package snowberry
+
+import "time"
+
+// Unassign retracts one earlier assignment of input, see UnassignN.
+func (c *Counter) Unassign(input string) (ClusterID, bool) {
+	return c.UnassignN(input, 1)
+}
+
+// UnassignN retracts n earlier assignments of input from the category it matches, found the same way Assign finds it.
+// A category whose count drops to zero is removed from the index entirely. Only the count of a remaining category is
+// retracted: its history and rates still hold the retracted members, see UnassignNAt, and its first seen, last seen,
+// template and exemplars are kept as they are. UnassignN returns the ID of the category, and false when input was
+// rejected or matched no category, or n is less than 1.
+//
+// Retracting by input is approximate: the categories created since input was assigned may match it better than the
+// one it joined, and then lose the retraction instead. Keep the ID returned by Assign and use UnassignID to retract
+// from exactly that category.
+func (c *Counter) UnassignN(input string, n int) (ClusterID, bool) {
+	return c.UnassignNAt(input, n, time.Time{})
+}
+
+// UnassignNAt is UnassignN for assignments which occurred at the provided time, as passed to AssignAt or AssignNAt.
+// Besides the count, the members are also retracted from the history bucket and the rates of that time, so a sliding
+// window can retract expired members. The zero time retracts only the count, as UnassignN does. Like UnassignN, it
+// matches input approximately, see UnassignID.
+func (c *Counter) UnassignNAt(input string, n int, at time.Time) (ClusterID, bool) {
+	f, rejected := c.prepare(input, &AssignDebug{Input: input})
+	if rejected || n < 1 {
+		return "", false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, bestMatch, bestScore := c.search(c.tree, f)
+	if bestMatch == nil || bestScore <= c.scoreThreshold {
+		return "", false
+	}
+
+	c.unassign(c.clusters[bestMatch.id], n, at)
+
+	return bestMatch.id, true
+}
+
+// UnassignID retracts n earlier assignments from the category with the provided ID, as returned by Assign, in the same
+// way as UnassignNAt, including the zero time retracting only the count. Unlike retracting by input, it always retracts
+// from the category the assignments joined. UnassignID returns false when there is no such category, or n is less
+// than 1.
+func (c *Counter) UnassignID(id ClusterID, n int, at time.Time) bool {
+	if n < 1 {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cl, ok := c.clusters[id]
+	if !ok {
+		return false
+	}
+
+	c.unassign(cl, n, at)
+
+	return true
+}
+
+// unassign retracts n members of the cluster at the provided time, removing the cluster once its count drops to zero
+func (c *Counter) unassign(cl *cluster, n int, at time.Time) {
+	cl.remove(n, at)
+	if cl.count <= 0 {
+		c.removeCluster(cl)
+	} else if c.evictionOrder != nil {
+		c.evictionOrder.counted(cl)
+	}
+}
+
+// removeCluster removes the cluster and its fruit, pruning branches left empty
+func (c *Counter) removeCluster(cl *cluster) {
+	delete(c.clusters, cl.fruit.id)
+	if c.evictionOrder != nil {
+		c.evictionOrder.remove(cl)
+	}
+
+	f := cl.fruit
+	if c.tree.removeFruit(f) && f.length() >= c.tree.end() {
+		key := f.key(c.tree.start, c.tree.end())
+		if _, ok := c.tree.branches[key]; !ok {
+			c.shards.Delete(key)
+		}
+	}
+}
+
+// removeFruit removes f from the branch or its descendants, pruning descendants left empty and folding a descendant
+// left with a single fruit back into a leaf, which is where addFruit would have put that fruit. It reports whether f
+// was found.
+func (b *branch) removeFruit(f *fruit) bool {
+	for i, fr := range b.fruit {
+		if fr == f {
+			b.fruit = append(b.fruit[:i:i], b.fruit[i+1:]...)
+			return true
+		}
+	}
+
+	if f.length() < b.end() {
+		return false
+	}
+
+	key := f.key(b.start, b.end())
+	child, ok := b.branches[key]
+	if !ok || !child.removeFruit(f) {
+		return false
+	}
+
+	switch remaining := child.firstFruit(2); len(remaining) {
+	case 0:
+		delete(b.branches, key)
+	case 1:
+		child.fruit = remaining
+		child.branches = make(map[string]*branch)
+	}
+
+	return true
+}
+
+// firstFruit returns up to n of the fruit on the branch and its descendants
+func (b *branch) firstFruit(n int) []*fruit {
+	found := b.fruit[:min(n, len(b.fruit)):min(n, len(b.fruit))]
+	for _, child := range b.branches {
+		if len(found) == n {
+			break
+		}
+		found = append(found, child.firstFruit(n-len(found))...)
+	}
+
+	return found
+}
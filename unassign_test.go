@@ -0,0 +1,134 @@
+package snowberry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnassign(t *testing.T) {
+	c := NewCounter(2, 0.70)
+
+	snake := c.Assign("There's a snake in my boot.")
+	c.Assign("There's a snail in my boot.")
+	c.Assign("There's a boot in my boot.")
+	beyond := c.Assign("To infinity and beyond!")
+
+	id, ok := c.Unassign("There's a snail in my boot.")
+	assert.True(t, ok)
+	assert.Equal(t, snake.ID, id)
+	assert.Equal(t, map[ClusterID]int{snake.ID: 2, beyond.ID: 1}, c.CountsByID())
+
+	_, ok = c.Unassign("You've got a friend in me.")
+	assert.False(t, ok)
+
+	id, ok = c.UnassignN("There's a snake in my boot.", 5)
+	assert.True(t, ok)
+	assert.Equal(t, snake.ID, id)
+	assert.Equal(t, map[ClusterID]int{beyond.ID: 1}, c.CountsByID())
+
+	// Branches left without fruit are pruned
+	_, ok = c.Unassign("To infinity and beyond!")
+	assert.True(t, ok)
+	assert.Empty(t, c.CountsByID())
+	assert.Equal(t, &branch{step: 2, branches: map[string]*branch{}}, c.tree)
+
+	// Removed categories no longer match, and can be created again
+	_, ok = c.Unassign("To infinity and beyond!")
+	assert.False(t, ok)
+	assert.True(t, c.Assign("To infinity and beyond!").Created)
+}
+
+func TestRemoveFruit(t *testing.T) {
+	f := []string{
+		"An aardvark ate my apple.",
+		"An apple is a fruit.",
+		"My favorite fruit is mango.",
+	}
+
+	root := &branch{step: 2, branches: map[string]*branch{}}
+
+	var added []*fruit
+	for _, word := range f {
+		n := newFruit(word).segment(false)
+		root.findTerminatingBranch(n).addFruit(n)
+		added = append(added, n)
+	}
+
+	assert.True(t, root.removeFruit(added[0]))
+	assert.False(t, root.removeFruit(added[0]))
+	assert.Equal(t, []*fruit{added[1]}, root.branches["An"].allDescendantFruit())
+	// The lone remaining fruit is folded back to where a fresh insert puts it
+	assert.Equal(t, []*fruit{added[1]}, root.branches["An"].fruit)
+	assert.Empty(t, root.branches["An"].branches)
+
+	assert.True(t, root.removeFruit(added[1]))
+	assert.NotContains(t, root.branches, "An")
+	assert.Contains(t, root.branches, "My")
+}
+
+func TestUnassignNAt(t *testing.T) {
+	at := time.Date(2024, 9, 8, 23, 30, 0, 0, time.UTC)
+	newCounter := func() *Counter {
+		return NewCounter(5, 0.60).WithHistory(time.Minute, 0).WithRates(time.Minute, time.Hour)
+	}
+
+	c := newCounter()
+	c.AssignNAt("User alice failed login", 2, at)
+	c.AssignAt("User bob failed login", at.Add(time.Minute))
+	c.AssignAt("User carol failed login", at.Add(2*time.Minute))
+
+	// Counts alone leave history and rates as they were
+	c.UnassignN("User carol failed login", 1)
+	assert.Len(t, c.Clusters()[0].History, 3)
+
+	c.UnassignNAt("User alice failed login", 1, at)
+	c.UnassignNAt("User bob failed login", 1, at.Add(time.Minute))
+
+	cl := c.Clusters()[0]
+	assert.Equal(t, 1, cl.Count)
+	var counts []int
+	for _, b := range cl.History {
+		counts = append(counts, b.Count)
+	}
+	assert.Equal(t, []int{1, 1}, counts)
+
+	expected := newCounter()
+	expected.AssignAt("User alice failed login", at)
+	expected.AssignAt("User carol failed login", at.Add(2*time.Minute))
+	now := at.Add(3 * time.Minute)
+	assert.InDelta(t, expected.HotClusters(now, 1)[0].Rate, c.HotClusters(now, 1)[0].Rate, 1e-12)
+	assert.InDelta(t, expected.HotClusters(now, 1)[0].Baseline, c.HotClusters(now, 1)[0].Baseline, 1e-12)
+}
+
+func TestUnassignID(t *testing.T) {
+	newCounter := func() (*Counter, ClusterID, ClusterID) {
+		c := NewCounter(20, 0.65)
+		a := c.Assign("aaaaaaaaaa")
+		assert.Equal(t, a.ID, c.Assign("aaaaaaaxxx").ID)
+		b := c.Assign("aaaaaxxxxx")
+		assert.True(t, b.Created)
+
+		return c, a.ID, b.ID
+	}
+
+	// Retracting by input matches the category created since, which is closer than the one the input joined
+	c, a, b := newCounter()
+	id, ok := c.Unassign("aaaaaaaxxx")
+	assert.True(t, ok)
+	assert.Equal(t, b, id)
+	assert.Equal(t, map[ClusterID]int{a: 2}, c.CountsByID())
+
+	// Retracting by ID does not
+	c, a, b = newCounter()
+	assert.True(t, c.UnassignID(a, 1, time.Time{}))
+	assert.Equal(t, map[ClusterID]int{a: 1, b: 1}, c.CountsByID())
+
+	assert.True(t, c.UnassignID(b, 1, time.Time{}))
+	assert.Equal(t, map[ClusterID]int{a: 1}, c.CountsByID())
+
+	assert.False(t, c.UnassignID(b, 1, time.Time{}))
+	assert.False(t, c.UnassignID(a, 0, time.Time{}))
+	assert.Equal(t, map[ClusterID]int{a: 1}, c.CountsByID())
+}